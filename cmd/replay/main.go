@@ -0,0 +1,291 @@
+// replay 将 middleware.WithTrafficCapture 采样的流量回放到目标服务,对比状态码、响应 body 与耗时
+//
+//	replay -target http://127.0.0.1:8080 log/traffic.log log/traffic-2024-01-01T00-00-00.000.log.gz
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guomoumou123/contrib/middleware"
+)
+
+var skipHeaders = map[string]struct{}{
+	"Host":              {},
+	"Content-Length":    {},
+	"Connection":        {},
+	"Keep-Alive":        {},
+	"Transfer-Encoding": {},
+	"Upgrade":           {},
+	"Te":                {},
+	"Trailer":           {},
+}
+
+// headerFlags 回放时附加的请求头,用于替换采样时被脱敏的认证信息
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headerFlags) Set(v string) error {
+	k, val, ok := strings.Cut(v, ":")
+	if !ok {
+		return fmt.Errorf("invalid header %q, want \"Name: value\"", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(k), strings.TrimSpace(val))
+	return nil
+}
+
+type result struct {
+	exchange   middleware.CapturedExchange
+	skipped    bool
+	status     int
+	body       []byte
+	latency    time.Duration
+	err        error
+	statusDiff bool
+	bodyDiff   bool
+}
+
+func main() {
+	target := flag.String("target", "", "回放目标地址,如 http://127.0.0.1:8080")
+	concurrency := flag.Int("concurrency", 4, "并发数")
+	timeout := flag.Duration("timeout", 10*time.Second, "单个请求超时时间")
+	compareBody := flag.Bool("body", true, "是否对比响应 body")
+	show := flag.Int("show", 20, "最多输出的差异条数")
+	headers := headerFlags{}
+	flag.Var(headers, "H", "附加请求头,可重复,如 -H \"Authorization: Bearer xxx\"")
+	flag.Parse()
+	if *target == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: replay -target <base url> <capture file>...")
+		os.Exit(2)
+	}
+
+	exchanges := make(chan middleware.CapturedExchange)
+	results := make(chan result)
+	client := &http.Client{Timeout: *timeout}
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ex := range exchanges {
+				results <- replay(client, strings.TrimRight(*target, "/"), ex, http.Header(headers), *compareBody)
+			}
+		}()
+	}
+	go func() {
+		for _, file := range flag.Args() {
+			if err := readExchanges(file, exchanges); err != nil {
+				fmt.Fprintf(os.Stderr, "read %s failed: %v\n", file, err)
+			}
+		}
+		close(exchanges)
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		total, failed, skipped, statusDiffs, bodyDiffs, shown int
+		oldLatency, newLatency                                []time.Duration
+	)
+	for r := range results {
+		if r.skipped {
+			skipped++
+			continue
+		}
+		total++
+		if r.err != nil {
+			failed++
+			if shown < *show {
+				shown++
+				fmt.Printf("ERROR %s %s trace_id=%s: %v\n", r.exchange.Method, r.exchange.Path, r.exchange.TraceId, r.err)
+			}
+			continue
+		}
+		oldLatency = append(oldLatency, time.Duration(r.exchange.LatencyMs*float64(time.Millisecond)))
+		newLatency = append(newLatency, r.latency)
+		if r.statusDiff {
+			statusDiffs++
+		}
+		if r.bodyDiff {
+			bodyDiffs++
+		}
+		if (r.statusDiff || r.bodyDiff) && shown < *show {
+			shown++
+			fmt.Printf("DIFF  %s %s trace_id=%s status %d -> %d\n", r.exchange.Method, r.exchange.Path, r.exchange.TraceId, r.exchange.Status, r.status)
+			if r.bodyDiff {
+				fmt.Printf("      - %s\n      + %s\n", abbreviate(r.exchange.ResponseBody), abbreviate(string(r.body)))
+			}
+		}
+	}
+
+	fmt.Printf("\nreplayed: %d, skipped: %d, errors: %d, status diffs: %d, body diffs: %d\n", total, skipped, failed, statusDiffs, bodyDiffs)
+	fmt.Printf("%-8s %12s %12s %10s\n", "latency", "captured", "replayed", "change")
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		o, n := percentile(oldLatency, q), percentile(newLatency, q)
+		change := "-"
+		if o > 0 {
+			change = fmt.Sprintf("%+.1f%%", (float64(n)-float64(o))/float64(o)*100)
+		}
+		fmt.Printf("p%-7.0f %12s %12s %10s\n", q*100, o.Round(time.Microsecond), n.Round(time.Microsecond), change)
+	}
+	if failed > 0 || statusDiffs > 0 || bodyDiffs > 0 {
+		os.Exit(1)
+	}
+}
+
+// readExchanges 逐行读取采样文件,轮转后压缩的 .gz 文件同样支持
+func readExchanges(file string, out chan<- middleware.CapturedExchange) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var ex middleware.CapturedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			fmt.Fprintf(os.Stderr, "skip invalid line in %s: %v\n", file, err)
+			continue
+		}
+		out <- ex
+	}
+	return scanner.Err()
+}
+
+func replay(client *http.Client, target string, ex middleware.CapturedExchange, headers http.Header, compareBody bool) result {
+	res := result{exchange: ex}
+	//请求 body 被截断时回放的是不完整的请求,跳过
+	if ex.RequestTruncated {
+		res.skipped = true
+		return res
+	}
+	url := target + ex.Path
+	if ex.Query != "" {
+		url += "?" + ex.Query
+	}
+	req, err := http.NewRequest(ex.Method, url, strings.NewReader(ex.RequestBody))
+	if err != nil {
+		res.err = err
+		return res
+	}
+	for k, values := range ex.RequestHeader {
+		if _, ok := skipHeaders[http.CanonicalHeaderKey(k)]; ok {
+			continue
+		}
+		for _, v := range values {
+			if v != middleware.RedactedValue {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	for k, values := range headers {
+		req.Header[k] = values
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer resp.Body.Close()
+	res.body, res.err = io.ReadAll(resp.Body)
+	res.latency = time.Since(start)
+	res.status = resp.StatusCode
+	res.statusDiff = res.status != ex.Status
+	//整体被脱敏的 body 无法对比
+	if compareBody && res.err == nil && ex.ResponseBody != middleware.RedactedValue {
+		//采样的 body 被截断时只对比采样长度内的内容
+		body := res.body
+		if ex.ResponseTruncated && len(body) > len(ex.ResponseBody) {
+			body = body[:len(ex.ResponseBody)]
+		}
+		res.bodyDiff = !equalBody([]byte(ex.ResponseBody), body)
+	}
+	return res
+}
+
+// equalBody JSON body 按语义对比,忽略字段顺序与空白,采样时被脱敏的字段不参与对比
+func equalBody(captured, replayed []byte) bool {
+	if bytes.Equal(captured, replayed) {
+		return true
+	}
+	var vc, vr interface{}
+	if json.Unmarshal(captured, &vc) != nil || json.Unmarshal(replayed, &vr) != nil {
+		return false
+	}
+	vr = maskRedacted(vc, vr)
+	jc, _ := json.Marshal(vc)
+	jr, _ := json.Marshal(vr)
+	return bytes.Equal(jc, jr)
+}
+
+// maskRedacted 将回放结果中与采样时被脱敏字段对应的值同样替换为脱敏值
+func maskRedacted(captured, replayed interface{}) interface{} {
+	if s, ok := captured.(string); ok && s == middleware.RedactedValue {
+		return s
+	}
+	switch c := captured.(type) {
+	case map[string]interface{}:
+		r, ok := replayed.(map[string]interface{})
+		if !ok {
+			return replayed
+		}
+		for k, v := range c {
+			if rv, ok := r[k]; ok {
+				r[k] = maskRedacted(v, rv)
+			}
+		}
+	case []interface{}:
+		r, ok := replayed.([]interface{})
+		if !ok {
+			return replayed
+		}
+		for i := 0; i < len(c) && i < len(r); i++ {
+			r[i] = maskRedacted(c[i], r[i])
+		}
+	}
+	return replayed
+}
+
+func percentile(values []time.Duration, q float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(float64(len(sorted)-1) * q)
+	return sorted[idx]
+}
+
+func abbreviate(s string) string {
+	if len(s) > 200 {
+		return s[:200] + "..."
+	}
+	return s
+}
@@ -13,7 +13,10 @@ type (
 	}
 )
 
-const TraceContextKey contextKey = 1
+const (
+	TraceContextKey contextKey = 1
+	debugContextKey contextKey = 2
+)
 
 func GetTraceId(ctx context.Context) string {
 	span := trace.SpanFromContext(ctx)
@@ -31,3 +34,16 @@ func GetTraceId(ctx context.Context) string {
 
 	return ""
 }
+
+// WithDebug 标记当前请求为调试请求: 强制采样并开启 Debug 级别日志
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugContextKey, true)
+}
+
+func IsDebug(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	debug, _ := ctx.Value(debugContextKey).(bool)
+	return debug
+}
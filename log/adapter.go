@@ -0,0 +1,142 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Adapt 将只实现了 BasicLogCore 的自定义 logger 转换为 LogCore,已实现 LogCore 时原样返回
+//
+// Named 的名字作为 logger 字段输出,With 的字段追加在每条日志后,未实现 DebugWithCtx 时调用 Debug
+//
+//	log.NewGormLogger(log.Adapt(custom), log.Info)
+func Adapt(l BasicLogCore) LogCore {
+	if lc, ok := l.(LogCore); ok {
+		return lc
+	}
+	return &basicAdapter{inner: l}
+}
+
+type basicAdapter struct {
+	inner  BasicLogCore
+	name   string
+	fields []Field
+}
+
+func (a *basicAdapter) SetPrefix(name string) LogCore {
+	return &basicAdapter{inner: a.inner, name: name, fields: a.fields}
+}
+
+func (a *basicAdapter) Named(name string) LogCore {
+	if a.name != "" && name != "" {
+		name = a.name + "." + name
+	} else if name == "" {
+		name = a.name
+	}
+	return a.SetPrefix(name)
+}
+
+func (a *basicAdapter) With(fields ...Field) LogCore {
+	f := make([]Field, 0, len(a.fields)+len(fields))
+	f = append(append(f, a.fields...), fields...)
+	return &basicAdapter{inner: a.inner, name: a.name, fields: f}
+}
+
+// args 非 context 方法在消息前加名字,消息后追加绑定的字段
+func (a *basicAdapter) args(args []interface{}) []interface{} {
+	if a.name == "" && len(a.fields) == 0 {
+		return args
+	}
+	var b strings.Builder
+	if a.name != "" {
+		b.WriteString(a.name)
+		b.WriteByte(' ')
+	}
+	fmt.Fprint(&b, args...)
+	for _, f := range a.fields {
+		if f.kind != skipKind {
+			fmt.Fprintf(&b, " %s=%v", f.key, f.any())
+		}
+	}
+	return []interface{}{b.String()}
+}
+
+// ctxFields 依次为名字、绑定的字段、context 中保存的字段与调用处的字段
+func (a *basicAdapter) ctxFields(ctx context.Context, fields []Field) []Field {
+	scoped := scopedFields(ctx, fields)
+	if a.name == "" && len(a.fields) == 0 && len(scoped) == 0 {
+		return fields
+	}
+	f := make([]Field, 0, len(fields)+len(a.fields)+len(scoped)+1)
+	if a.name != "" {
+		f = append(f, Any("logger", a.name))
+	}
+	f = append(append(f, a.fields...), scoped...)
+	return append(f, fields...)
+}
+
+func (a *basicAdapter) Info(args ...interface{})  { a.inner.Info(a.args(args)...) }
+func (a *basicAdapter) Error(args ...interface{}) { a.inner.Error(a.args(args)...) }
+func (a *basicAdapter) Debug(args ...interface{}) { a.inner.Debug(a.args(args)...) }
+func (a *basicAdapter) Warn(args ...interface{})  { a.inner.Warn(a.args(args)...) }
+func (a *basicAdapter) Fatal(args ...interface{}) { a.inner.Fatal(a.args(args)...) }
+
+func (a *basicAdapter) Debugf(format string, args ...interface{}) {
+	a.Debug(fmt.Sprintf(format, args...))
+}
+
+func (a *basicAdapter) Infof(format string, args ...interface{}) {
+	a.Info(fmt.Sprintf(format, args...))
+}
+
+func (a *basicAdapter) Warnf(format string, args ...interface{}) {
+	a.Warn(fmt.Sprintf(format, args...))
+}
+
+func (a *basicAdapter) Errorf(format string, args ...interface{}) {
+	a.Error(fmt.Sprintf(format, args...))
+}
+
+func (a *basicAdapter) Fatalf(format string, args ...interface{}) {
+	a.Fatal(fmt.Sprintf(format, args...))
+}
+
+func (a *basicAdapter) DebugWithCtx(ctx context.Context, msg string, fields ...Field) {
+	if d, ok := a.inner.(interface {
+		DebugWithCtx(ctx context.Context, msg string, fields ...Field)
+	}); ok {
+		d.DebugWithCtx(ctx, msg, a.ctxFields(ctx, fields)...)
+		return
+	}
+	a.Debug(debugArgs(ctx, msg, fields)...)
+}
+
+func (a *basicAdapter) InfoWithCtx(ctx context.Context, msg string, fields ...Field) {
+	a.inner.InfoWithCtx(ctx, msg, a.ctxFields(ctx, fields)...)
+}
+
+func (a *basicAdapter) ErrorWithCtx(ctx context.Context, msg string, fields ...Field) {
+	a.inner.ErrorWithCtx(ctx, msg, a.ctxFields(ctx, fields)...)
+}
+
+func (a *basicAdapter) WarnWithCtx(ctx context.Context, msg string, fields ...Field) {
+	a.inner.WarnWithCtx(ctx, msg, a.ctxFields(ctx, fields)...)
+}
+
+func (a *basicAdapter) FatalWithCtx(ctx context.Context, msg string, fields ...Field) {
+	a.inner.FatalWithCtx(ctx, msg, a.ctxFields(ctx, fields)...)
+}
+
+// debugArgs 字段按 key=value 追加在消息后
+func debugArgs(ctx context.Context, msg string, fields []Field) []interface{} {
+	args := []interface{}{msg}
+	for _, list := range [][]Field{scopedFields(ctx, fields), fields} {
+		for _, f := range list {
+			if f.kind != skipKind {
+				args = append(args, fmt.Sprintf(" %s=%v", f.key, f.any()))
+			}
+		}
+	}
+	return args
+}
@@ -0,0 +1,309 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	innermetrics "github.com/guomoumou123/contrib/otlp/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	OverflowBlock          = "block"            //缓冲满时阻塞等待
+	OverflowDropNewest     = "drop_newest"      //丢弃新日志
+	OverflowDropOldest     = "drop_oldest"      //丢弃最早的日志
+	OverflowDropBelowLevel = "drop_below_level" //丢弃低于 DropLevel 的新日志,其余阻塞等待
+)
+
+var (
+	defaultAsyncBufferSize     = 8192
+	defaultAsyncBatchSize      = 256
+	defaultAsyncFlushInterval  = time.Second
+	defaultAsyncReportInterval = time.Minute
+)
+
+// AsyncConfig 异步写入配置,日志在调用方编码后进入有界缓冲,由后台协程批量写入
+type AsyncConfig struct {
+	BufferSize     int           //缓冲条数,默认 8192
+	BatchSize      int           //缓冲达到该条数时立即写入,默认 256
+	FlushInterval  time.Duration //最长写入间隔,默认 1s
+	Overflow       string        //缓冲满时的策略 block/drop_newest/drop_oldest/drop_below_level,默认 block
+	DropLevel      string        //drop_below_level 策略下可丢弃的级别上限(不含),默认 warn
+	ReportInterval time.Duration //丢弃数量写入日志的间隔,默认 1m
+}
+
+type asyncEntry struct {
+	level Level
+	data  []byte
+}
+
+// asyncWriter 环形缓冲与后台写入,由同一输出的全部 asyncCore 共享
+type asyncWriter struct {
+	mu        sync.Mutex
+	notFull   *sync.Cond
+	ring      []asyncEntry
+	head      int
+	count     int
+	batch     int
+	overflow  string
+	dropLevel Level
+	ws        zapcore.WriteSyncer
+	enc       zapcore.Encoder
+	notify    chan struct{}
+	syncCh    chan chan error
+	dropped   atomic.Int64
+	counter   metric.Int64Counter
+	closer    io.Closer     //Close 写完缓冲后关闭的底层输出
+	closed    bool          //关闭后的日志直接写入底层输出
+	stop      chan struct{} //通知后台协程退出
+	stopped   chan struct{} //后台协程已写完缓冲并退出
+	stopOnce  sync.Once
+}
+
+func newAsyncWriter(conf *AsyncConfig, enc zapcore.Encoder, ws zapcore.WriteSyncer, closer io.Closer) *asyncWriter {
+	c := *conf
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultAsyncBufferSize
+	}
+	if c.BatchSize <= 0 || c.BatchSize > c.BufferSize {
+		c.BatchSize = min(defaultAsyncBatchSize, c.BufferSize)
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultAsyncFlushInterval
+	}
+	if c.ReportInterval <= 0 {
+		c.ReportInterval = defaultAsyncReportInterval
+	}
+	dropLevel := WarnLevel
+	if c.DropLevel != "" {
+		if lvl, err := ParseLevel(c.DropLevel); err == nil {
+			dropLevel = lvl
+		}
+	}
+	w := &asyncWriter{
+		ring:      make([]asyncEntry, c.BufferSize),
+		batch:     c.BatchSize,
+		overflow:  strings.ToLower(c.Overflow),
+		dropLevel: dropLevel,
+		ws:        ws,
+		enc:       enc.Clone(),
+		notify:    make(chan struct{}, 1),
+		syncCh:    make(chan chan error),
+		closer:    closer,
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	w.notFull = sync.NewCond(&w.mu)
+	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
+	w.counter, _ = meter.Int64Counter("log.dropped")
+	go w.run(c.FlushInterval, c.ReportInterval)
+	return w
+}
+
+func (w *asyncWriter) push(lvl Level, data []byte) {
+	w.mu.Lock()
+	for w.count == len(w.ring) && !w.closed {
+		switch {
+		case w.overflow == OverflowDropNewest,
+			w.overflow == OverflowDropBelowLevel && lvl < w.dropLevel:
+			w.mu.Unlock()
+			w.drop(lvl)
+			return
+		case w.overflow == OverflowDropOldest:
+			old := w.ring[w.head]
+			w.ring[w.head] = asyncEntry{}
+			w.head = (w.head + 1) % len(w.ring)
+			w.count--
+			w.drop(old.level)
+		default:
+			w.signal()
+			w.notFull.Wait()
+		}
+	}
+	if w.closed {
+		_, _ = w.ws.Write(data)
+		w.mu.Unlock()
+		return
+	}
+	w.ring[(w.head+w.count)%len(w.ring)] = asyncEntry{level: lvl, data: data}
+	w.count++
+	full := w.count >= w.batch
+	w.mu.Unlock()
+	if full {
+		w.signal()
+	}
+}
+
+func (w *asyncWriter) drop(lvl Level) {
+	w.dropped.Add(1)
+	if w.counter != nil {
+		w.counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("level", lvl.String())))
+	}
+}
+
+func (w *asyncWriter) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *asyncWriter) run(flushInterval, reportInterval time.Duration) {
+	flush := time.NewTicker(flushInterval)
+	report := time.NewTicker(reportInterval)
+	defer flush.Stop()
+	defer report.Stop()
+	var buf []byte
+	for {
+		select {
+		case <-w.notify:
+		case <-flush.C:
+		case <-report.C:
+			w.report()
+			continue
+		case done := <-w.syncCh:
+			buf = w.drain(buf)
+			done <- w.ws.Sync()
+			continue
+		case <-w.stop:
+			w.mu.Lock()
+			w.closed = true
+			w.notFull.Broadcast()
+			w.mu.Unlock()
+			w.drain(buf)
+			w.report()
+			close(w.stopped)
+			return
+		}
+		buf = w.drain(buf)
+	}
+}
+
+// drain 按批取出缓冲中的日志合并为一次写入
+func (w *asyncWriter) drain(buf []byte) []byte {
+	for {
+		w.mu.Lock()
+		n := min(w.count, w.batch)
+		buf = buf[:0]
+		for i := 0; i < n; i++ {
+			idx := (w.head + i) % len(w.ring)
+			buf = append(buf, w.ring[idx].data...)
+			w.ring[idx] = asyncEntry{}
+		}
+		w.head = (w.head + n) % len(w.ring)
+		w.count -= n
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+		if n == 0 {
+			return buf
+		}
+		_, _ = w.ws.Write(buf)
+	}
+}
+
+func (w *asyncWriter) report() {
+	n := w.dropped.Swap(0)
+	if n == 0 {
+		return
+	}
+	ent := zapcore.Entry{Level: WarnLevel, Time: time.Now(), Message: "async log entries dropped"}
+	b, err := w.enc.EncodeEntry(ent, []zapcore.Field{{Key: "dropped", Type: zapcore.Int64Type, Integer: n}})
+	if err != nil {
+		return
+	}
+	_, _ = w.ws.Write(b.Bytes())
+	b.Free()
+}
+
+// Sync 写入缓冲中的全部日志并同步底层输出
+func (w *asyncWriter) Sync() error {
+	done := make(chan error, 1)
+	select {
+	case w.syncCh <- done:
+		return <-done
+	case <-w.stopped:
+		return w.ws.Sync()
+	}
+}
+
+// Close 写入缓冲中的全部日志后停止后台协程与定时器,再关闭底层输出,之后的日志同步写入
+func (w *asyncWriter) Close() error {
+	var err error
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.stopped
+		err = w.ws.Sync()
+		if w.closer != nil {
+			err = errors.Join(err, w.closer.Close())
+		}
+	})
+	return err
+}
+
+// asyncCore 与 zapcore.NewCore 相同,只是编码后的日志交给 asyncWriter 写入
+type asyncCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   *asyncWriter
+}
+
+func newAsyncCore(enc zapcore.Encoder, ws zapcore.WriteSyncer, enab zapcore.LevelEnabler, conf *AsyncConfig, closer io.Closer) *asyncCore {
+	return &asyncCore{LevelEnabler: enab, enc: enc, w: newAsyncWriter(conf, enc, ws, closer)}
+}
+
+func (c *asyncCore) Level() zapcore.Level {
+	return zapcore.LevelOf(c.LevelEnabler)
+}
+
+func (c *asyncCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &asyncCore{LevelEnabler: c.LevelEnabler, enc: enc, w: c.w}
+}
+
+func (c *asyncCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *asyncCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	b, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	data := append([]byte(nil), b.Bytes()...)
+	b.Free()
+	c.w.push(ent.Level, data)
+	//与 zapcore.NewCore 一致,Panic/Fatal 日志立即写入,避免进程退出时丢失
+	if ent.Level > ErrorLevel {
+		return c.w.Sync()
+	}
+	return nil
+}
+
+func (c *asyncCore) Sync() error {
+	return c.w.Sync()
+}
+
+// newIOCore 按配置创建同步或异步写入的 core,异步写入时返回的 closer 先写完缓冲再关闭 closer
+func newIOCore(conf *Config, ws zapcore.WriteSyncer, enab zapcore.LevelEnabler, closer io.Closer) (zapcore.Core, io.Closer) {
+	enc := newEncoder(conf)
+	if conf != nil && conf.Async != nil {
+		c := newAsyncCore(enc, ws, enab, conf.Async, closer)
+		return c, c.w
+	}
+	return zapcore.NewCore(enc, ws, enab), closer
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"context"
+)
+
+type fieldsContextKey struct{}
+
+// WithFields 将字段保存在 context 中,之后传入该 context 的 *WithCtx 日志自动输出这些字段
+//
+// 多次调用时字段累加,同名字段以后设置的为准,不输出的字段(如 log.Err(nil))不保存,
+// 输出时与调用处的字段同名的以调用处为准
+//
+//	ctx = log.WithFields(ctx, log.String("order_id", id))
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	add := make([]Field, 0, len(fields))
+	for _, v := range fields {
+		if v.kind != skipKind {
+			add = append(add, v)
+		}
+	}
+	if len(add) == 0 {
+		return ctx
+	}
+	old := ContextFields(ctx)
+	f := make([]Field, 0, len(old)+len(add))
+	for _, v := range old {
+		if !hasField(add, v.key) {
+			f = append(f, v)
+		}
+	}
+	f = append(f, add...)
+	return context.WithValue(ctx, fieldsContextKey{}, f)
+}
+
+// ContextFields 返回 WithFields 保存的字段,自定义 LogCore 实现可据此输出
+func ContextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(fieldsContextKey{}).([]Field)
+	return f
+}
+
+// scopedFields 返回 context 中与调用处字段不同名的字段
+func scopedFields(ctx context.Context, fields []Field) []Field {
+	scoped := ContextFields(ctx)
+	for i, v := range scoped {
+		if !hasField(fields, v.key) {
+			continue
+		}
+		//存在同名字段时才复制
+		f := make([]Field, i, len(scoped)-1)
+		copy(f, scoped[:i])
+		for _, v := range scoped[i+1:] {
+			if !hasField(fields, v.key) {
+				f = append(f, v)
+			}
+		}
+		return f
+	}
+	return scoped
+}
+
+func hasField(fields []Field, key string) bool {
+	for _, f := range fields {
+		if f.key == key {
+			return true
+		}
+	}
+	return false
+}
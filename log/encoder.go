@@ -0,0 +1,83 @@
+package log
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+
+	TimeFormatRFC3339     = "rfc3339"
+	TimeFormatRFC3339Nano = "rfc3339nano"
+	TimeFormatEpochMillis = "epoch_millis"
+
+	defaultTraceIdKey    = "trace_id"
+	defaultSpanIdKey     = "span_id"
+	defaultTraceFlagsKey = "trace_flags"
+	omitKey              = "-"
+)
+
+// newEncoder 按配置创建 encoder,conf 为空时与原有的 console 输出保持一致
+func newEncoder(conf *Config) zapcore.Encoder {
+	if conf == nil {
+		conf = &Config{}
+	}
+	encoderCfg := zap.NewDevelopmentEncoderConfig()
+	encoderCfg.TimeKey = encoderKey(conf.TimeKey, "time")
+	encoderCfg.LevelKey = encoderKey(conf.LevelKey, "level")
+	encoderCfg.MessageKey = encoderKey(conf.MessageKey, "msg")
+	encoderCfg.CallerKey = encoderKey(conf.CallerKey, "caller")
+	encoderCfg.NameKey = "logger"
+	encoderCfg.StacktraceKey = "stacktrace"
+	encoderCfg.EncodeTime = timeEncoder(conf.TimeFormat)
+	encoderCfg.EncodeDuration = zapcore.StringDurationEncoder
+
+	switch strings.ToLower(conf.Format) {
+	case FormatJSON:
+		encoderCfg.EncodeCaller = zapcore.ShortCallerEncoder
+		return zapcore.NewJSONEncoder(encoderCfg)
+	case FormatLogfmt:
+		encoderCfg.EncodeCaller = zapcore.ShortCallerEncoder
+		return newLogfmtEncoder(encoderCfg)
+	}
+	encoderCfg.EncodeCaller = shortCallerEncoder //日志调用方法
+	return zapcore.NewConsoleEncoder(encoderCfg)
+}
+
+func encoderKey(key, def string) string {
+	switch key {
+	case "":
+		return def
+	case omitKey:
+		return ""
+	}
+	return key
+}
+
+// traceKeys 返回 trace_id/span_id/trace_flags 的字段名
+func traceKeys(conf *Config) (traceId, spanId, traceFlags string) {
+	if conf == nil {
+		conf = &Config{}
+	}
+	return encoderKey(conf.TraceIdKey, defaultTraceIdKey), encoderKey(conf.SpanIdKey, defaultSpanIdKey), encoderKey(conf.TraceFlagsKey, defaultTraceFlagsKey)
+}
+
+func timeEncoder(format string) zapcore.TimeEncoder {
+	switch strings.ToLower(format) {
+	case "", TimeFormatRFC3339:
+		return zapcore.RFC3339TimeEncoder
+	case TimeFormatRFC3339Nano:
+		return zapcore.RFC3339NanoTimeEncoder
+	case TimeFormatEpochMillis:
+		return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(t.UnixMilli())
+		}
+	}
+	return zapcore.TimeEncoderOfLayout(format)
+}
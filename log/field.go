@@ -0,0 +1,197 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type fieldKind uint8
+
+const (
+	anyKind fieldKind = iota
+	skipKind
+	stringKind
+	int64Kind
+	boolKind
+	durationKind
+	timeKind
+	errorKind
+	stringerKind
+	objectKind
+)
+
+// Field 日志字段,常用类型按值保存,输出到 zap 时不经过反射
+type Field struct {
+	key   string
+	kind  fieldKind
+	num   int64
+	str   string
+	value interface{}
+}
+
+// ObjectMarshaler 嵌套对象按字段输出,与 zap 的接口一致
+type (
+	ObjectMarshaler = zapcore.ObjectMarshaler
+	ObjectEncoder   = zapcore.ObjectEncoder
+)
+
+func Any(key string, value interface{}) Field {
+	return Field{key: key, value: value}
+}
+
+func String(key string, value string) Field {
+	return Field{key: key, kind: stringKind, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{key: key, kind: int64Kind, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{key: key, kind: int64Kind, num: value}
+}
+
+func Bool(key string, value bool) Field {
+	var num int64
+	if value {
+		num = 1
+	}
+	return Field{key: key, kind: boolKind, num: num}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{key: key, kind: durationKind, num: int64(value)}
+}
+
+// Time 超出 UnixNano 范围的时间按 Any 保存
+func Time(key string, value time.Time) Field {
+	if value.Before(minTime) || value.After(maxTime) {
+		return Any(key, value)
+	}
+	return Field{key: key, kind: timeKind, num: value.UnixNano(), value: value.Location()}
+}
+
+// Err 字段名为 error,err 为空时不输出
+func Err(err error) Field {
+	if err == nil {
+		return Field{kind: skipKind}
+	}
+	return Field{key: "error", kind: errorKind, value: err}
+}
+
+// Stringer 输出时才调用 String,value 为 nil 时按 Any 输出
+func Stringer(key string, value fmt.Stringer) Field {
+	if value == nil {
+		return Any(key, nil)
+	}
+	return Field{key: key, kind: stringerKind, value: value}
+}
+
+// Object value 为 nil 时按 Any 输出
+func Object(key string, value ObjectMarshaler) Field {
+	if value == nil {
+		return Any(key, nil)
+	}
+	return Field{key: key, kind: objectKind, value: value}
+}
+
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+func (f Field) zapField() zapcore.Field {
+	switch f.kind {
+	case skipKind:
+		return zap.Skip()
+	case stringKind:
+		return zap.String(f.key, f.str)
+	case int64Kind:
+		return zap.Int64(f.key, f.num)
+	case boolKind:
+		return zap.Bool(f.key, f.num == 1)
+	case durationKind:
+		return zap.Duration(f.key, time.Duration(f.num))
+	case timeKind:
+		return zap.Time(f.key, f.time())
+	case errorKind:
+		return zap.NamedError(f.key, f.value.(error))
+	case stringerKind:
+		return zap.Stringer(f.key, f.value.(fmt.Stringer))
+	case objectKind:
+		return zap.Object(f.key, f.value.(ObjectMarshaler))
+	}
+	return zap.Any(f.key, f.value)
+}
+
+func (f Field) slogAttr() slog.Attr {
+	switch f.kind {
+	case skipKind:
+		return slog.Attr{}
+	case stringKind:
+		return slog.String(f.key, f.str)
+	case int64Kind:
+		return slog.Int64(f.key, f.num)
+	case boolKind:
+		return slog.Bool(f.key, f.num == 1)
+	case durationKind:
+		return slog.Duration(f.key, time.Duration(f.num))
+	case timeKind:
+		return slog.Time(f.key, f.time())
+	case stringerKind:
+		return slog.String(f.key, f.value.(fmt.Stringer).String())
+	}
+	return slog.Any(f.key, f.any())
+}
+
+func (f Field) time() time.Time {
+	if loc, ok := f.value.(*time.Location); ok {
+		return time.Unix(0, f.num).In(loc)
+	}
+	return time.Unix(0, f.num)
+}
+
+// any 返回字段值,对象按字段展开为 map,数组展开为 slice,用于 zap 之外的输出
+func (f Field) any() interface{} {
+	switch f.kind {
+	case skipKind:
+		return nil
+	case stringKind:
+		return f.str
+	case int64Kind:
+		return f.num
+	case boolKind:
+		return f.num == 1
+	case durationKind:
+		return time.Duration(f.num)
+	case timeKind:
+		return f.time()
+	case objectKind, anyKind:
+		return marshalValue(f.value)
+	}
+	return f.value
+}
+
+// marshalValue 通过 zapcore.MapObjectEncoder 展开实现了 zap 对象/数组编码的值,其它值原样返回
+func marshalValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case ObjectMarshaler:
+		enc := zapcore.NewMapObjectEncoder()
+		if err := val.MarshalLogObject(enc); err != nil {
+			return err.Error()
+		}
+		return enc.Fields
+	case zapcore.ArrayMarshaler:
+		enc := zapcore.NewMapObjectEncoder()
+		if err := enc.AddArray("v", val); err != nil {
+			return err.Error()
+		}
+		return enc.Fields["v"]
+	}
+	return v
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 使用变量而不是常量,避免编译器将常量装箱优化为静态数据
+var (
+	benchStr  = "20240101000001"
+	benchInt  = 10086
+	benchBool = true
+	benchCost = time.Millisecond
+	benchTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	benchErr  = errors.New("not found")
+	sinkField zapcore.Field
+)
+
+// Field 与 zapcore.Field 同为 64 字节,传参开销与直接使用 zap 相同
+func TestFieldSize(t *testing.T) {
+	if got, want := unsafe.Sizeof(Field{}), unsafe.Sizeof(zapcore.Field{}); got > want {
+		t.Fatalf("Field is %d bytes, want <= zapcore.Field %d bytes", got, want)
+	}
+}
+
+// TestFieldAllocs 常用类型构造并转换为 zap 字段时不分配内存,zap.Any 的分配次数作为参照输出
+func TestFieldAllocs(t *testing.T) {
+	cases := []struct {
+		name  string
+		typed func() zapcore.Field
+		any   func() zapcore.Field
+	}{
+		{"String", func() zapcore.Field { return String("k", benchStr).zapField() }, func() zapcore.Field { return zap.Any("k", benchStr) }},
+		{"Int", func() zapcore.Field { return Int("k", benchInt).zapField() }, func() zapcore.Field { return zap.Any("k", benchInt) }},
+		{"Bool", func() zapcore.Field { return Bool("k", benchBool).zapField() }, func() zapcore.Field { return zap.Any("k", benchBool) }},
+		{"Duration", func() zapcore.Field { return Duration("k", benchCost).zapField() }, func() zapcore.Field { return zap.Any("k", benchCost) }},
+		{"Time", func() zapcore.Field { return Time("k", benchTime).zapField() }, func() zapcore.Field { return zap.Any("k", benchTime) }},
+		{"Err", func() zapcore.Field { return Err(benchErr).zapField() }, func() zapcore.Field { return zap.Any("error", benchErr) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if n := testing.AllocsPerRun(100, func() { sinkField = c.typed() }); n != 0 {
+				t.Errorf("typed field allocs = %v, want 0", n)
+			}
+			t.Logf("zap.Any allocs = %v", testing.AllocsPerRun(100, func() { sinkField = c.any() }))
+		})
+	}
+}
+
+// TestFieldNil nil 值与 zap.Stringer/zap.Any 一致,输出时不 panic
+func TestFieldNil(t *testing.T) {
+	for _, f := range []Field{Stringer("k", nil), Object("k", nil), Err(nil), Any("k", nil)} {
+		_ = f.zapField()
+		_ = f.slogAttr()
+		_ = f.any()
+	}
+	var buf bytes.Buffer
+	l := newZapLogger(&buf, &Config{Format: "json"}, nil)
+	l.InfoWithCtx(context.Background(), "nil", Stringer("s", nil), Object("o", nil))
+	if got := buf.String(); !strings.Contains(got, `"s":null`) || !strings.Contains(got, `"o":null`) {
+		t.Errorf("got %s, want null values", got)
+	}
+}
+
+type testObject map[string]string
+
+func (o testObject) MarshalLogObject(enc ObjectEncoder) error {
+	for k, v := range o {
+		enc.AddString(k, v)
+	}
+	return nil
+}
+
+type testArray []string
+
+func (a testArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range a {
+		enc.AppendString(v)
+	}
+	return nil
+}
+
+// TestFieldMarshalerSlog Any 保存的 zap 对象/数组在 slog 输出时按结构展开
+func TestFieldMarshalerSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.NewJSONHandler(&buf, nil))
+	l.InfoWithCtx(context.Background(), "req",
+		Any("body", testObject{"id": "1"}),
+		Object("header", testObject{"ua": "curl"}),
+		Any("items", testArray{"a", "b"}),
+	)
+	got := buf.String()
+	for _, want := range []string{`"body":{"id":"1"}`, `"header":{"ua":"curl"}`, `"items":["a","b"]`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func benchLogger() (*zapLogger, *zap.Logger) {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(enc, zapcore.AddSync(io.Discard), zap.DebugLevel)
+	return newCoreLogger(core, nil, InfoLevel, nil, nil).(*zapLogger), zap.New(core)
+}
+
+func BenchmarkFieldTyped(b *testing.B) {
+	l, _ := benchLogger()
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.InfoWithCtx(ctx, "order paid",
+			String("order_id", benchStr),
+			Int("user_id", benchInt),
+			Bool("retry", benchBool),
+			Duration("cost", benchCost),
+			Time("paid_at", benchTime),
+		)
+	}
+}
+
+func BenchmarkFieldAny(b *testing.B) {
+	l, _ := benchLogger()
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.InfoWithCtx(ctx, "order paid",
+			Any("order_id", benchStr),
+			Any("user_id", benchInt),
+			Any("retry", benchBool),
+			Any("cost", benchCost),
+			Any("paid_at", benchTime),
+		)
+	}
+}
+
+// BenchmarkFieldZapAny 直接使用 zap.Any 作为参照
+func BenchmarkFieldZapAny(b *testing.B) {
+	_, z := benchLogger()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		z.Info("order paid",
+			zap.Any("order_id", benchStr),
+			zap.Any("user_id", benchInt),
+			zap.Any("retry", benchBool),
+			zap.Any("cost", benchCost),
+			zap.Any("paid_at", benchTime),
+		)
+	}
+}
@@ -63,9 +63,9 @@ func (g GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (strin
 	sql, rows := fc()
 	switch {
 	case err != nil:
-		g.logger.ErrorWithCtx(ctx, trimPath(), Any("err", err.Error()), Any("elapsed", fmt.Sprintf("%vms", float64(elapsed.Nanoseconds())/1e6)), Any("rows", rows), Any("sql", sql))
+		g.logger.ErrorWithCtx(ctx, trimPath(), String("err", err.Error()), String("elapsed", fmt.Sprintf("%vms", float64(elapsed.Nanoseconds())/1e6)), Int64("rows", rows), String("sql", sql))
 	default:
-		g.logger.InfoWithCtx(ctx, trimPath(), Any("elapsed", fmt.Sprintf("%vms", float64(elapsed.Nanoseconds())/1e6)), Any("rows", rows), Any("sql", sql))
+		g.logger.InfoWithCtx(ctx, trimPath(), String("elapsed", fmt.Sprintf("%vms", float64(elapsed.Nanoseconds())/1e6)), Int64("rows", rows), String("sql", sql))
 	}
 }
 
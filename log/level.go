@@ -0,0 +1,179 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelController 运行时调整日志级别,name 为 Named 设置的 logger 名,为空时表示根级别
+//
+// 名字按 "." 分段,未单独设置的 logger 沿用最长前缀匹配的级别,如 "order.pay" 沿用 "order" 的设置
+type LevelController interface {
+	Level(name string) Level
+	SetLevel(name string, lvl Level)
+	ResetLevel(name string)
+	Levels() (root Level, overrides map[string]Level)
+}
+
+// Levels 返回 logger 的级别控制,logger 不支持时返回 nil
+func Levels(l LogCore) LevelController {
+	if c, ok := l.(LevelController); ok {
+		return c
+	}
+	return nil
+}
+
+// ParseLevel 解析 debug/info/warn/error/fatal
+func ParseLevel(text string) (Level, error) {
+	return zapcore.ParseLevel(strings.ToLower(strings.TrimSpace(text)))
+}
+
+// configLevel 优先使用 Level,为空时按 Debug 决定,conf 为空时与默认配置一致开启调试
+func configLevel(conf *Config) (Level, error) {
+	if conf == nil {
+		return DebugLevel, nil
+	}
+	def := InfoLevel
+	if conf.Debug {
+		def = DebugLevel
+	}
+	if conf.Level == "" {
+		return def, nil
+	}
+	lvl, err := ParseLevel(conf.Level)
+	if err != nil {
+		return def, err
+	}
+	return lvl, nil
+}
+
+// levelRegistry 同一 NewLogger 创建的 logger 及其子 logger 共享
+type levelRegistry struct {
+	mu        sync.Mutex
+	root      zap.AtomicLevel
+	overrides atomic.Pointer[map[string]Level] //写时复制,读取无锁
+	writer    *zap.Logger
+}
+
+func newLevelRegistry(lvl Level, writer *zap.Logger) *levelRegistry {
+	return &levelRegistry{root: zap.NewAtomicLevelAt(lvl), writer: writer}
+}
+
+func (r *levelRegistry) level(name string) Level {
+	if m := r.overrides.Load(); m != nil && len(*m) > 0 {
+		for name != "" {
+			if lvl, ok := (*m)[name]; ok {
+				return lvl
+			}
+			i := strings.LastIndexByte(name, '.')
+			if i < 0 {
+				break
+			}
+			name = name[:i]
+		}
+	}
+	return r.root.Level()
+}
+
+func (r *levelRegistry) set(name string, lvl Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	from := r.level(name)
+	if name == "" {
+		r.root.SetLevel(lvl)
+	} else {
+		r.store(func(m map[string]Level) { m[name] = lvl })
+	}
+	r.writer.Info("log level changed", zap.String("logger", name), zap.Stringer("from", from), zap.Stringer("to", lvl))
+}
+
+func (r *levelRegistry) reset(name string) {
+	if name == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	from := r.level(name)
+	r.store(func(m map[string]Level) { delete(m, name) })
+	r.writer.Info("log level reset", zap.String("logger", name), zap.Stringer("from", from), zap.Stringer("to", r.level(name)))
+}
+
+func (r *levelRegistry) store(update func(m map[string]Level)) {
+	m := make(map[string]Level)
+	if old := r.overrides.Load(); old != nil {
+		for k, v := range *old {
+			m[k] = v
+		}
+	}
+	update(m)
+	r.overrides.Store(&m)
+}
+
+func (r *levelRegistry) snapshot() (Level, map[string]Level) {
+	m := make(map[string]Level)
+	if old := r.overrides.Load(); old != nil {
+		for k, v := range *old {
+			m[k] = v
+		}
+	}
+	return r.root.Level(), m
+}
+
+type levelPayload struct {
+	Name  string `json:"name,omitempty"`
+	Level string `json:"level"`
+}
+
+type levelsPayload struct {
+	Level     string            `json:"level"`
+	Overrides map[string]string `json:"overrides"`
+}
+
+// LevelHandler 查看与修改日志级别
+//
+//	GET    返回 {"level":"info","overrides":{"order":"debug"}}
+//	PUT    body 为 {"name":"order","level":"debug"},name 为空时修改根级别
+//	DELETE ?name=order 删除单独设置的级别
+func LevelHandler(l LogCore) http.Handler {
+	c := Levels(l)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c == nil {
+			http.Error(w, "logger does not support level changes", http.StatusNotImplemented)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var p levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			lvl, err := ParseLevel(p.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			c.SetLevel(p.Name, lvl)
+		case http.MethodDelete:
+			c.ResetLevel(r.URL.Query().Get("name"))
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		root, overrides := c.Levels()
+		resp := levelsPayload{Level: root.String(), Overrides: make(map[string]string, len(overrides))}
+		for k, v := range overrides {
+			resp.Overrides[k] = v.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,291 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder 按 key=value 输出单行日志,嵌套对象与数组以 JSON 字符串作为值
+type logfmtEncoder struct {
+	*zapcore.EncoderConfig
+	buf        *buffer.Buffer
+	namespaces []string
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{EncoderConfig: &cfg, buf: logfmtPool.Get()}
+}
+
+func (enc *logfmtEncoder) clone() *logfmtEncoder {
+	return &logfmtEncoder{
+		EncoderConfig: enc.EncoderConfig,
+		buf:           logfmtPool.Get(),
+		namespaces:    enc.namespaces[:len(enc.namespaces):len(enc.namespaces)],
+	}
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	c := enc.clone()
+	c.buf.AppendBytes(enc.buf.Bytes())
+	return c
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+	final.namespaces = nil
+	if final.TimeKey != "" {
+		final.addKey(final.TimeKey)
+		final.EncodeTime(ent.Time, final)
+	}
+	if final.LevelKey != "" {
+		final.addKey(final.LevelKey)
+		final.EncodeLevel(ent.Level, final)
+	}
+	if final.NameKey != "" && ent.LoggerName != "" {
+		final.addKey(final.NameKey)
+		final.AppendString(ent.LoggerName)
+	}
+	if final.CallerKey != "" && ent.Caller.Defined {
+		final.addKey(final.CallerKey)
+		final.EncodeCaller(ent.Caller, final)
+	}
+	if final.MessageKey != "" {
+		final.addKey(final.MessageKey)
+		final.AppendString(ent.Message)
+	}
+	if enc.buf.Len() > 0 {
+		if final.buf.Len() > 0 {
+			final.buf.AppendByte(' ')
+		}
+		final.buf.AppendBytes(enc.buf.Bytes())
+	}
+	final.namespaces = enc.namespaces
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+	final.namespaces = nil
+	if final.StacktraceKey != "" && ent.Stack != "" {
+		final.addKey(final.StacktraceKey)
+		final.AppendString(ent.Stack)
+	}
+	if final.LineEnding != "" {
+		final.buf.AppendString(final.LineEnding)
+	} else {
+		final.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+	return final.buf, nil
+}
+
+func (enc *logfmtEncoder) addKey(key string) {
+	if enc.buf.Len() > 0 {
+		enc.buf.AppendByte(' ')
+	}
+	for _, ns := range enc.namespaces {
+		enc.appendKey(ns)
+		enc.buf.AppendByte('.')
+	}
+	enc.appendKey(key)
+	enc.buf.AppendByte('=')
+}
+
+// appendKey key 中的空白、等号与引号替换为下划线
+func (enc *logfmtEncoder) appendKey(key string) {
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			enc.buf.AppendByte('_')
+		} else {
+			enc.buf.AppendByte(c)
+		}
+	}
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// appendJSON 嵌套结构先编码为 JSON 再作为字符串值输出
+func (enc *logfmtEncoder) appendJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	enc.AppendString(string(b))
+	return nil
+}
+
+func (enc *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	enc.addKey(key)
+	return enc.appendJSON(m.Fields[key])
+}
+
+func (enc *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := obj.MarshalLogObject(m); err != nil {
+		return err
+	}
+	enc.addKey(key)
+	return enc.appendJSON(m.Fields)
+}
+
+func (enc *logfmtEncoder) AddReflected(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	enc.addKey(key)
+	enc.AppendString(string(b))
+	return nil
+}
+
+func (enc *logfmtEncoder) OpenNamespace(key string) {
+	enc.namespaces = append(enc.namespaces, key)
+}
+
+func (enc *logfmtEncoder) AddBinary(key string, v []byte) {
+	enc.AddString(key, base64.StdEncoding.EncodeToString(v))
+}
+
+func (enc *logfmtEncoder) AddByteString(key string, v []byte) {
+	enc.addKey(key)
+	enc.AppendByteString(v)
+}
+
+func (enc *logfmtEncoder) AddBool(key string, v bool) {
+	enc.addKey(key)
+	enc.AppendBool(v)
+}
+
+func (enc *logfmtEncoder) AddComplex128(key string, v complex128) {
+	enc.addKey(key)
+	enc.AppendComplex128(v)
+}
+
+func (enc *logfmtEncoder) AddComplex64(key string, v complex64) {
+	enc.addKey(key)
+	enc.AppendComplex64(v)
+}
+
+func (enc *logfmtEncoder) AddDuration(key string, v time.Duration) {
+	enc.addKey(key)
+	if enc.EncodeDuration != nil {
+		enc.EncodeDuration(v, enc)
+		return
+	}
+	enc.AppendInt64(int64(v))
+}
+
+func (enc *logfmtEncoder) AddFloat64(key string, v float64) {
+	enc.addKey(key)
+	enc.AppendFloat64(v)
+}
+
+func (enc *logfmtEncoder) AddFloat32(key string, v float32) {
+	enc.addKey(key)
+	enc.AppendFloat32(v)
+}
+
+func (enc *logfmtEncoder) AddInt(key string, v int)     { enc.AddInt64(key, int64(v)) }
+func (enc *logfmtEncoder) AddInt32(key string, v int32) { enc.AddInt64(key, int64(v)) }
+func (enc *logfmtEncoder) AddInt16(key string, v int16) { enc.AddInt64(key, int64(v)) }
+func (enc *logfmtEncoder) AddInt8(key string, v int8)   { enc.AddInt64(key, int64(v)) }
+
+func (enc *logfmtEncoder) AddInt64(key string, v int64) {
+	enc.addKey(key)
+	enc.AppendInt64(v)
+}
+
+func (enc *logfmtEncoder) AddString(key, v string) {
+	enc.addKey(key)
+	enc.AppendString(v)
+}
+
+func (enc *logfmtEncoder) AddTime(key string, v time.Time) {
+	enc.addKey(key)
+	if enc.EncodeTime != nil {
+		enc.EncodeTime(v, enc)
+		return
+	}
+	enc.AppendInt64(v.UnixNano())
+}
+
+func (enc *logfmtEncoder) AddUint(key string, v uint)       { enc.AddUint64(key, uint64(v)) }
+func (enc *logfmtEncoder) AddUint32(key string, v uint32)   { enc.AddUint64(key, uint64(v)) }
+func (enc *logfmtEncoder) AddUint16(key string, v uint16)   { enc.AddUint64(key, uint64(v)) }
+func (enc *logfmtEncoder) AddUint8(key string, v uint8)     { enc.AddUint64(key, uint64(v)) }
+func (enc *logfmtEncoder) AddUintptr(key string, v uintptr) { enc.AddUint64(key, uint64(v)) }
+
+func (enc *logfmtEncoder) AddUint64(key string, v uint64) {
+	enc.addKey(key)
+	enc.AppendUint64(v)
+}
+
+// 以下 Append 方法写入 key= 之后的值,供 EncodeTime/EncodeLevel 等编码函数使用
+
+func (enc *logfmtEncoder) AppendBool(v bool) { enc.buf.AppendBool(v) }
+
+func (enc *logfmtEncoder) AppendByteString(v []byte) { enc.AppendString(string(v)) }
+
+func (enc *logfmtEncoder) AppendComplex128(v complex128) {
+	enc.buf.AppendString(strconv.FormatComplex(v, 'g', -1, 128))
+}
+
+func (enc *logfmtEncoder) AppendComplex64(v complex64) {
+	enc.buf.AppendString(strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+func (enc *logfmtEncoder) AppendFloat64(v float64) { enc.appendFloat(v, 64) }
+func (enc *logfmtEncoder) AppendFloat32(v float32) { enc.appendFloat(float64(v), 32) }
+
+func (enc *logfmtEncoder) appendFloat(v float64, bitSize int) {
+	switch {
+	case math.IsNaN(v):
+		enc.buf.AppendString("NaN")
+	case math.IsInf(v, 1):
+		enc.buf.AppendString("+Inf")
+	case math.IsInf(v, -1):
+		enc.buf.AppendString("-Inf")
+	default:
+		enc.buf.AppendFloat(v, bitSize)
+	}
+}
+
+func (enc *logfmtEncoder) AppendInt(v int)     { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtEncoder) AppendInt64(v int64) { enc.buf.AppendInt(v) }
+func (enc *logfmtEncoder) AppendInt32(v int32) { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtEncoder) AppendInt16(v int16) { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtEncoder) AppendInt8(v int8)   { enc.buf.AppendInt(int64(v)) }
+
+func (enc *logfmtEncoder) AppendString(v string) {
+	if needsQuote(v) {
+		enc.buf.AppendString(strconv.Quote(v))
+		return
+	}
+	enc.buf.AppendString(v)
+}
+
+func (enc *logfmtEncoder) AppendUint(v uint)       { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtEncoder) AppendUint64(v uint64)   { enc.buf.AppendUint(v) }
+func (enc *logfmtEncoder) AppendUint32(v uint32)   { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtEncoder) AppendUint16(v uint16)   { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtEncoder) AppendUint8(v uint8)     { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtEncoder) AppendUintptr(v uintptr) { enc.buf.AppendUint(uint64(v)) }
@@ -5,16 +5,67 @@ import (
 	"io"
 	"os"
 
+	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-type Field struct {
-	key   string
-	value interface{}
+type Level = zapcore.Level
+
+const (
+	DebugLevel = zapcore.DebugLevel
+	InfoLevel  = zapcore.InfoLevel
+	WarnLevel  = zapcore.WarnLevel
+	ErrorLevel = zapcore.ErrorLevel
+	FatalLevel = zapcore.FatalLevel
+)
+
+// LevelEnabler 可选接口,调用方据此跳过被级别过滤的日志的字段构造
+type LevelEnabler interface {
+	Enabled(ctx context.Context, lvl Level) bool
+}
+
+// Enabled 判断 logger 在 ctx 下是否输出 lvl 级别日志,未实现 LevelEnabler 的 logger 视为全部输出
+func Enabled(l LogCore, ctx context.Context, lvl Level) bool {
+	if e, ok := l.(LevelEnabler); ok {
+		return e.Enabled(ctx, lvl)
+	}
+	return true
+}
+
+// Sync 写入 logger 缓冲中的日志,未实现 Sync 的 logger 直接返回
+func Sync(l LogCore) error {
+	if s, ok := l.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close 写入缓冲中的日志并关闭日志文件、停止后台协程,未实现 Close 的 logger 直接返回
+//
+// 关闭的资源由同一 logger 创建的全部子 logger 共享,通常在进程退出前调用一次
+func Close(l LogCore) error {
+	if c, ok := l.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 type LogCore interface {
+	BasicLogCore
+	// Deprecated: 使用 Named
 	SetPrefix(msg string) LogCore
+	Named(name string) LogCore
+	With(fields ...Field) LogCore
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	DebugWithCtx(ctx context.Context, msg string, fields ...Field)
+}
+
+// BasicLogCore 扩展前 LogCore 的输出方法,自定义实现可通过 Adapt 转换为 LogCore
+type BasicLogCore interface {
 	Info(args ...interface{})
 	Error(args ...interface{})
 	Debug(args ...interface{})
@@ -27,13 +78,30 @@ type LogCore interface {
 }
 
 type Config struct {
-	Debug       bool   //是否开启调试
-	MaxSize     int    //日志文件最大多少兆
-	MaxAge      int    //日志文件保留天数
-	MaxBackups  int    //保留文件数
-	FileName    string //日志名字
-	Compress    bool   //日志生成压缩包,大幅降低磁盘空间,必要时使用
-	RotateByDay bool   //每天轮转一次,如果开启,maxBackups的值需要>=maxDays
+	Debug          bool                 //是否开启调试,Level 为空时生效
+	Level          string               //日志级别 debug/info/warn/error,运行时可通过 LevelController 调整
+	MaxSize        int                  //日志文件最大多少兆
+	MaxAge         int                  //日志文件保留天数
+	MaxBackups     int                  //保留文件数
+	FileName       string               //日志名字
+	Compress       bool                 //日志生成压缩包,大幅降低磁盘空间,必要时使用
+	RotateByDay    bool                 //每天轮转一次,如果开启,maxBackups的值需要>=maxDays
+	RotateSchedule string               //按时间轮转的周期 daily/hourly 或 cron 表达式 "分 时 日 月 周",为空且开启 RotateByDay 时按 daily
+	FilePattern    string               //按时间轮转的文件名模板,支持 %Y %m %d %H %M,默认在 FileName 后追加日期
+	Format         string               //日志格式 console/json/logfmt,默认 console
+	TimeFormat     string               //时间格式 rfc3339/rfc3339nano/epoch_millis,其它值按 Go 时间模板处理,默认 rfc3339
+	TimeKey        string               //时间字段名,默认 time,"-" 表示不输出
+	LevelKey       string               //级别字段名,默认 level,"-" 表示不输出
+	MessageKey     string               //消息字段名,默认 msg
+	CallerKey      string               //调用方字段名,默认 caller,"-" 表示不输出
+	TraceIdKey     string               //trace id 字段名,默认 trace_id,"-" 表示不输出
+	SpanIdKey      string               //span id 字段名,默认 span_id,"-" 表示不输出
+	TraceFlagsKey  string               //trace flags 字段名,默认 trace_flags,"-" 表示不输出
+	SpanEventLevel string               //不为空时该级别及以上的带 context 日志同时记录为当前 span 的 log 事件,如 warn
+	Sinks          []SinkConfig         //multi 类型的输出列表,每个 sink 单独配置级别、格式与轮转
+	Async          *AsyncConfig         //不为空时异步批量写入,磁盘阻塞不影响调用方
+	Sampling       *SamplingConfig      //不为空时按级别+消息采样或合并重复日志
+	TraceSampling  *TraceSamplingConfig //不为空时按 trace 的采样结果保留日志
 }
 
 func initWriter(conf *Config) io.Writer {
@@ -57,6 +125,10 @@ func initWriter(conf *Config) io.Writer {
 		conf.MaxBackups = defaultMaxBackups
 	}
 
+	if conf.RotateByDay || conf.RotateSchedule != "" {
+		return newRotateWriter(conf)
+	}
+
 	return &lumberjack.Logger{
 		Filename:   conf.FileName,
 		MaxSize:    conf.MaxSize,
@@ -67,20 +139,21 @@ func initWriter(conf *Config) io.Writer {
 	}
 }
 
+// NewWriter 按日志配置创建轮转文件 writer,供日志之外需要落盘的场景复用
+func NewWriter(conf *Config) io.Writer {
+	return initWriter(conf)
+}
+
 func NewLogger(lc *Config, loggerType string) LogCore {
 	switch loggerType {
 	case "file":
 		w := initWriter(lc)
-		return newZapLogger(w, lc.Debug)
+		closer, _ := w.(io.Closer)
+		return newZapLogger(w, lc, closer)
 	case "stdout":
-		return newZapLogger(os.Stdout, true)
+		return newZapLogger(os.Stdout, lc, nil)
+	case "multi":
+		return newMultiLogger(lc)
 	}
 	return nil
 }
-
-func Any(key string, value interface{}) Field {
-	return Field{
-		key:   key,
-		value: value,
-	}
-}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+)
+
+// NewLogrSink 将 LogCore 作为 logr 的输出,V(0) 按 info 输出,V(1) 及以上按 debug 输出
+//
+//	otel.SetLogger(logr.New(log.NewLogrSink(logger.Named("otel"))))
+func NewLogrSink(l LogCore) logr.LogSink {
+	return &logrSink{l: l}
+}
+
+type logrSink struct {
+	l LogCore
+}
+
+func (s *logrSink) Init(info logr.RuntimeInfo) {
+	//跳过 logr.Logger 与 logrSink 自身
+	s.l = addCallerSkip(s.l, info.CallDepth+1)
+}
+
+func (s *logrSink) Enabled(level int) bool {
+	return Enabled(s.l, context.Background(), logrLevel(level))
+}
+
+func (s *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if logrLevel(level) == InfoLevel {
+		s.l.InfoWithCtx(context.Background(), msg, logrFields(nil, keysAndValues)...)
+		return
+	}
+	s.l.DebugWithCtx(context.Background(), msg, logrFields(nil, keysAndValues)...)
+}
+
+func (s *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	fields := make([]Field, 0, len(keysAndValues)/2+1)
+	if err != nil {
+		fields = append(fields, Any("error", err))
+	}
+	s.l.ErrorWithCtx(context.Background(), msg, logrFields(fields, keysAndValues)...)
+}
+
+func (s *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &logrSink{l: s.l.With(logrFields(nil, keysAndValues)...)}
+}
+
+func (s *logrSink) WithName(name string) logr.LogSink {
+	return &logrSink{l: s.l.Named(name)}
+}
+
+func (s *logrSink) WithCallDepth(depth int) logr.LogSink {
+	return &logrSink{l: addCallerSkip(s.l, depth)}
+}
+
+func logrLevel(level int) Level {
+	if level > 0 {
+		return DebugLevel
+	}
+	return InfoLevel
+}
+
+// logrFields 转换 logr 的键值对,键不是字符串时按 fmt 格式化,缺少值时输出 <no-value>
+func logrFields(fields []Field, keysAndValues []interface{}) []Field {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value interface{} = "<no-value>"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fields = append(fields, Any(key, value))
+	}
+	return fields
+}
@@ -0,0 +1,150 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	defaultNetworkTimeout    = time.Second
+	defaultNetworkBufferSize = 1024
+	networkMaxBackoff        = 30 * time.Second
+)
+
+// networkWriter 异步写入网络,Write 只入队不阻塞,队列满或连接不可用时丢弃日志,避免拖慢其它输出
+type networkWriter struct {
+	network string
+	address string
+	timeout time.Duration
+	queue   chan []byte
+	dropped atomic.Int64
+	mu      sync.RWMutex
+	closed  bool
+	stop    chan struct{} //Close 超时后通知后台协程丢弃剩余日志
+	done    chan struct{} //后台协程已退出并关闭连接
+}
+
+func newNetworkWriter(network, address string, timeout time.Duration, size int) *networkWriter {
+	if network == "" {
+		network = "tcp"
+	}
+	if timeout <= 0 {
+		timeout = defaultNetworkTimeout
+	}
+	if size <= 0 {
+		size = defaultNetworkBufferSize
+	}
+	w := &networkWriter{
+		network: network,
+		address: address,
+		timeout: timeout,
+		queue:   make(chan []byte, size),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *networkWriter) Write(p []byte) (int, error) {
+	//zap 复用 buffer,入队前需要拷贝
+	b := make([]byte, len(p))
+	copy(b, p)
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return len(p), nil
+	}
+	select {
+	case w.queue <- b:
+	default:
+		w.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+func (w *networkWriter) Sync() error {
+	return nil
+}
+
+// Close 停止接收日志,在 WriteTimeout 内发送队列中剩余的日志后关闭连接,超时未发送的日志丢弃
+func (w *networkWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-time.After(w.timeout):
+		close(w.stop)
+		<-w.done
+	}
+	return nil
+}
+
+func (w *networkWriter) run() {
+	var (
+		conn    net.Conn
+		backoff time.Duration
+		retryAt time.Time
+	)
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+		if n := w.dropped.Swap(0); n > 0 {
+			fmt.Fprintf(os.Stderr, "log: %d entries dropped by network sink %s\n", n, w.address)
+		}
+		close(w.done)
+	}()
+	for b := range w.queue {
+		select {
+		case <-w.stop:
+			w.dropped.Add(int64(len(w.queue)) + 1)
+			return
+		default:
+		}
+		if conn == nil {
+			if time.Now().Before(retryAt) {
+				w.dropped.Add(1)
+				continue
+			}
+			c, err := net.DialTimeout(w.network, w.address, w.timeout)
+			if err != nil {
+				backoff = w.fail("dial", err, backoff)
+				retryAt = time.Now().Add(backoff)
+				continue
+			}
+			conn = c
+			if n := w.dropped.Swap(0); n > 0 {
+				fmt.Fprintf(os.Stderr, "log: %d entries dropped by network sink %s\n", n, w.address)
+			}
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		if _, err := conn.Write(b); err != nil {
+			_ = conn.Close()
+			conn = nil
+			backoff = w.fail("write", err, backoff)
+			retryAt = time.Now().Add(backoff)
+			continue
+		}
+		backoff = 0
+	}
+}
+
+// fail 记录失败并返回指数退避的重试间隔,退避期间的日志直接丢弃
+func (w *networkWriter) fail(op string, err error, backoff time.Duration) time.Duration {
+	w.dropped.Add(1)
+	backoff = min(max(backoff*2, 100*time.Millisecond), networkMaxBackoff)
+	fmt.Fprintf(os.Stderr, "log: %s %s %s failed: %v, retry in %s\n", op, w.network, w.address, err, backoff)
+	return backoff
+}
@@ -0,0 +1,449 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RotateDaily  = "daily"
+	RotateHourly = "hourly"
+
+	megabyte = 1 << 20
+)
+
+// schedule 返回 t 之后的下一个轮转时间,返回零值表示不再轮转
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+type dailySchedule struct{}
+
+func (dailySchedule) next(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+type hourlySchedule struct{}
+
+func (hourlySchedule) next(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+}
+
+// cronSchedule 5 段 cron 表达式 "分 时 日 月 周",支持 * , - /
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func parseSchedule(spec string) (schedule, error) {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case RotateDaily, "@daily", "@midnight":
+		return dailySchedule{}, nil
+	case RotateHourly, "@hourly":
+		return hourlySchedule{}, nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid rotate schedule %q, want daily, hourly or 5 cron fields", spec)
+	}
+	var (
+		s   cronSchedule
+		err error
+	)
+	ranges := []struct {
+		dst      *uint64
+		min, max int
+	}{{&s.minute, 0, 59}, {&s.hour, 0, 23}, {&s.dom, 1, 31}, {&s.month, 1, 12}, {&s.dow, 0, 7}}
+	for i, r := range ranges {
+		if *r.dst, err = parseCronField(fields[i], r.min, r.max); err != nil {
+			return nil, fmt.Errorf("invalid rotate schedule %q: %w", spec, err)
+		}
+	}
+	//周日可以写作 0 或 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+	return &s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+		lo, hi := min, max
+		if expr != "*" {
+			loStr, hiStr, isRange := strings.Cut(expr, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) dayMatch(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	//日与周同时指定时满足其一即可,与 cron 一致
+	if !s.domAny && !s.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		y, m, d := t.Date()
+		switch {
+		case s.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatch(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// rotateWriter 按时间周期切换带日期的文件,周期内按大小轮转,备份文件名与 lumberjack 一致,
+// 过期清理与压缩在后台进行,覆盖全部周期的文件
+type rotateWriter struct {
+	mu         sync.Mutex
+	conf       Config
+	schedule   schedule
+	pattern    string
+	match      *regexp.Regexp
+	file       *os.File
+	filename   string
+	size       int64
+	nextRotate time.Time
+	millCh     chan struct{}
+	closeCh    chan struct{}
+	closeOnce  sync.Once
+}
+
+func newRotateWriter(conf *Config) *rotateWriter {
+	spec := conf.RotateSchedule
+	if spec == "" {
+		spec = RotateDaily
+	}
+	s, err := parseSchedule(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log: %v, fallback to daily\n", err)
+		s = dailySchedule{}
+	}
+	w := &rotateWriter{
+		conf:     *conf,
+		schedule: s,
+		pattern:  conf.FilePattern,
+		millCh:   make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+	}
+	if w.pattern == "" {
+		w.pattern = defaultFilePattern(conf.FileName, s)
+	}
+	w.match = patternRegexp(filepath.Base(w.pattern))
+	go w.millRun()
+	return w
+}
+
+// defaultFilePattern 在日志名后追加日期,如 log/default.log 按天轮转为 log/default-%Y-%m-%d.log
+func defaultFilePattern(name string, s schedule) string {
+	ext := filepath.Ext(name)
+	layout := "-%Y-%m-%dT%H-%M"
+	switch s.(type) {
+	case dailySchedule:
+		layout = "-%Y-%m-%d"
+	case hourlySchedule:
+		layout = "-%Y-%m-%dT%H"
+	}
+	return strings.TrimSuffix(name, ext) + layout + ext
+}
+
+// formatPattern 替换 %Y %m %d %H %M %%
+func formatPattern(pattern string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i == len(pattern)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
+
+// patternRegexp 匹配模板生成的文件名,以及 lumberjack 在扩展名前追加时间的大小轮转备份与压缩文件
+func patternRegexp(base string) *regexp.Regexp {
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(stem); i++ {
+		c := stem[i]
+		if c != '%' || i == len(stem)-1 {
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			continue
+		}
+		i++
+		switch stem[i] {
+		case 'Y':
+			b.WriteString(`\d{4}`)
+		case 'm', 'd', 'H', 'M':
+			b.WriteString(`\d{2}`)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteString(regexp.QuoteMeta(stem[i-1 : i+1]))
+		}
+	}
+	b.WriteString(`(-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3})?`)
+	b.WriteString(regexp.QuoteMeta(ext))
+	b.WriteString(`(\.gz)?$`)
+	return regexp.MustCompile(b.String())
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	//跨周期的写入在锁内完成切换,并发写入不会落到上一个周期的文件
+	now := time.Now()
+	if w.file == nil || (!w.nextRotate.IsZero() && !now.Before(w.nextRotate)) {
+		if err := w.openPeriod(now); err != nil {
+			return 0, err
+		}
+	}
+	if w.conf.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > int64(w.conf.MaxSize)*megabyte {
+		if err := w.rotateSize(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotateWriter) openPeriod(now time.Time) error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	w.filename = formatPattern(w.pattern, now)
+	w.nextRotate = w.schedule.next(now)
+	w.triggerMill()
+	return w.openFile()
+}
+
+// rotateSize 当前文件超过 MaxSize 时在扩展名前追加时间改名备份,再打开新文件
+func (w *rotateWriter) rotateSize(now time.Time) error {
+	_ = w.file.Close()
+	w.file = nil
+	ext := filepath.Ext(w.filename)
+	backup := strings.TrimSuffix(w.filename, ext) + "-" + now.Format("2006-01-02T15-04-05.000") + ext
+	if err := os.Rename(w.filename, backup); err != nil {
+		return err
+	}
+	w.triggerMill()
+	return w.openFile()
+}
+
+func (w *rotateWriter) openFile() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+func (w *rotateWriter) triggerMill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *rotateWriter) Close() error {
+	w.closeOnce.Do(func() { close(w.closeCh) })
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *rotateWriter) millRun() {
+	for {
+		select {
+		case <-w.millCh:
+			w.mill()
+		case <-w.closeCh:
+			return
+		}
+	}
+}
+
+func (w *rotateWriter) active() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.filename
+}
+
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// mill 按 MaxBackups 与 MaxAge 清理历史文件,开启 Compress 时压缩剩余文件
+func (w *rotateWriter) mill() {
+	files, err := w.rotatedFiles(w.active())
+	if err != nil {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	cutoff := time.Now().Add(-time.Duration(w.conf.MaxAge) * 24 * time.Hour)
+	for i, f := range files {
+		if (w.conf.MaxBackups > 0 && i >= w.conf.MaxBackups) || (w.conf.MaxAge > 0 && f.modTime.Before(cutoff)) {
+			_ = os.Remove(f.path)
+			continue
+		}
+		if w.conf.Compress && !strings.HasSuffix(f.path, ".gz") {
+			if err := compressFile(f.path); err != nil {
+				fmt.Fprintf(os.Stderr, "log: compress %s failed: %v\n", f.path, err)
+			}
+		}
+	}
+}
+
+// rotatedFiles 列出与模板完整匹配的历史文件及 lumberjack 的大小轮转备份,不包含当前写入的文件
+func (w *rotateWriter) rotatedFiles(active string) ([]rotatedFile, error) {
+	dir := filepath.Dir(w.pattern)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []rotatedFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !w.match.MatchString(name) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if path == filepath.Clean(active) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: path, modTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,272 @@
+package log
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	innermetrics "github.com/guomoumou123/contrib/otlp/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var defaultSamplingInterval = time.Second
+
+// SamplingConfig 日志采样与重复合并,按级别+消息统计
+type SamplingConfig struct {
+	Interval   time.Duration           //统计周期,默认 1s
+	First      int                     //每个周期内先输出的条数,为 0 且 Levels 为空时不采样
+	Thereafter int                     //超出 First 后每 Thereafter 条输出一条,为 0 时全部丢弃
+	Levels     map[string]SamplingRule //按级别单独设置,如 "error": {First: 10, Thereafter: 100}
+	Dedup      bool                    //合并周期内级别、消息与字段值都相同的日志,周期结束或 Sync 时输出最后一条并附带 repeated=N
+}
+
+type SamplingRule struct {
+	First      int
+	Thereafter int
+}
+
+type suppressCounter struct {
+	counter metric.Int64Counter
+}
+
+func newSuppressCounter() suppressCounter {
+	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
+	counter, _ := meter.Int64Counter("log.suppressed")
+	return suppressCounter{counter: counter}
+}
+
+func (s suppressCounter) add(lvl Level, reason string) {
+	if s.counter != nil {
+		s.counter.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("level", lvl.String()),
+			attribute.String("reason", reason),
+		))
+	}
+}
+
+// newSamplingCore 按配置包装采样与重复合并,重复合并在采样之前,被合并的日志不参与采样计数,
+// 开启重复合并时返回的 closer 输出未写入的合并日志并停止后台协程,ignore 中的字段(trace 关联字段)不参与重复判断
+func newSamplingCore(core zapcore.Core, conf *SamplingConfig, ignore ...string) (zapcore.Core, io.Closer) {
+	if conf == nil {
+		return core, nil
+	}
+	interval := conf.Interval
+	if interval <= 0 {
+		interval = defaultSamplingInterval
+	}
+	counter := newSuppressCounter()
+	hook := zapcore.SamplerHook(func(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+		if dec&zapcore.LogDropped != 0 {
+			counter.add(ent.Level, "sampled")
+		}
+	})
+	if len(conf.Levels) > 0 {
+		levels := make(map[Level]zapcore.Core, len(conf.Levels))
+		for name, rule := range conf.Levels {
+			lvl, err := ParseLevel(name)
+			if err != nil {
+				continue
+			}
+			levels[lvl] = zapcore.NewSamplerWithOptions(core, interval, rule.First, rule.Thereafter, hook)
+		}
+		fallback := core
+		if conf.First > 0 {
+			fallback = zapcore.NewSamplerWithOptions(core, interval, conf.First, conf.Thereafter, hook)
+		}
+		core = &levelSamplingCore{Core: fallback, levels: levels}
+	} else if conf.First > 0 {
+		core = zapcore.NewSamplerWithOptions(core, interval, conf.First, conf.Thereafter, hook)
+	}
+	if conf.Dedup {
+		c := newDedupCore(core, interval, counter, ignore)
+		return c, c.state
+	}
+	return core, nil
+}
+
+// levelSamplingCore 按级别分发到不同的采样 core
+type levelSamplingCore struct {
+	zapcore.Core
+	levels map[Level]zapcore.Core
+}
+
+func (c *levelSamplingCore) With(fields []zapcore.Field) zapcore.Core {
+	levels := make(map[Level]zapcore.Core, len(c.levels))
+	for lvl, core := range c.levels {
+		levels[lvl] = core.With(fields)
+	}
+	return &levelSamplingCore{Core: c.Core.With(fields), levels: levels}
+}
+
+func (c *levelSamplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core, ok := c.levels[ent.Level]; ok {
+		return core.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// dedupKey 字段值不同的日志不合并,fields 为绑定字段与调用处字段的 hash
+type dedupKey struct {
+	level  Level
+	name   string
+	msg    string
+	fields uint64
+}
+
+type dedupRecord struct {
+	repeated int
+	core     zapcore.Core
+	ent      zapcore.Entry
+	fields   []zapcore.Field
+}
+
+type dedupState struct {
+	mu       sync.Mutex
+	records  map[dedupKey]*dedupRecord
+	counter  suppressCounter
+	ignore   []string //不参与 hash 的字段名,合并的日志输出最后一条的值
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// dedupCore 周期内首条日志直接输出,之后的重复日志只计数,周期结束时输出最后一条并附带 repeated=N
+type dedupCore struct {
+	zapcore.Core
+	state *dedupState
+	scope uint64 //With 绑定字段的 hash
+}
+
+func newDedupCore(core zapcore.Core, interval time.Duration, counter suppressCounter, ignore []string) *dedupCore {
+	state := &dedupState{
+		records: make(map[dedupKey]*dedupRecord),
+		counter: counter,
+		ignore:  ignore,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go state.run(interval)
+	return &dedupCore{Core: core, state: state}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), state: c.state, scope: c.state.hashFields(c.scope, fields)}
+}
+
+// hashFields 按字段名、类型与值计算 hash,对象等复杂类型按编码后的结果计算
+func (s *dedupState) hashFields(seed uint64, fields []zapcore.Field) uint64 {
+	if len(fields) == 0 {
+		return seed
+	}
+	h := fnv.New64a()
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], seed)
+	_, _ = h.Write(b[:])
+	var enc *zapcore.MapObjectEncoder
+	for _, f := range fields {
+		if slices.Contains(s.ignore, f.Key) {
+			continue
+		}
+		_, _ = h.Write([]byte(f.Key))
+		binary.LittleEndian.PutUint64(b[:], uint64(f.Integer))
+		_, _ = h.Write([]byte{byte(f.Type)})
+		_, _ = h.Write(b[:])
+		_, _ = h.Write([]byte(f.String))
+		if f.Interface != nil {
+			if enc == nil {
+				enc = zapcore.NewMapObjectEncoder()
+			}
+			f.AddTo(enc)
+			fmt.Fprint(h, enc.Fields[f.Key])
+		}
+	}
+	return h.Sum64()
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	key := dedupKey{level: ent.Level, name: ent.LoggerName, msg: ent.Message, fields: c.state.hashFields(c.scope, fields)}
+	s := c.state
+	s.mu.Lock()
+	rec, ok := s.records[key]
+	if !ok {
+		s.records[key] = &dedupRecord{}
+		s.mu.Unlock()
+		return c.writeThrough(ent, fields)
+	}
+	rec.repeated++
+	rec.core, rec.ent = c.Core, ent
+	rec.fields = append(rec.fields[:0], fields...)
+	s.mu.Unlock()
+	s.counter.add(ent.Level, "dedup")
+	return nil
+}
+
+// writeThrough 经过内层 core 的 Check,保留采样等逻辑
+func (c *dedupCore) writeThrough(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// Sync 先输出未写入的合并日志
+func (c *dedupCore) Sync() error {
+	c.state.flush()
+	return c.Core.Sync()
+}
+
+func (s *dedupState) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			close(s.stopped)
+			return
+		}
+	}
+}
+
+// flush 输出周期内被合并的日志并开始新的周期
+func (s *dedupState) flush() {
+	s.mu.Lock()
+	records := s.records
+	s.records = make(map[dedupKey]*dedupRecord, len(records))
+	s.mu.Unlock()
+	for _, rec := range records {
+		if rec.repeated == 0 {
+			continue
+		}
+		fields := append(rec.fields, zap.Int("repeated", rec.repeated))
+		(&dedupCore{Core: rec.core}).writeThrough(rec.ent, fields)
+	}
+}
+
+// Close 输出未写入的合并日志并停止后台协程
+func (s *dedupState) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.stopped
+	})
+	return nil
+}
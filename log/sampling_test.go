@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanContext(t *testing.T, traceHex string) context.Context {
+	t.Helper()
+	traceId, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func decodeLines(t *testing.T, b []byte) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte("\n")) {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+// TestDedupAcrossTraces 不同请求中相同的日志合并,trace 字段不参与判断,合并的日志输出最后一条的 trace
+func TestDedupAcrossTraces(t *testing.T) {
+	var buf bytes.Buffer
+	l := newZapLogger(&buf, &Config{Format: "json", Sampling: &SamplingConfig{Dedup: true, Interval: time.Hour}}, nil)
+	defer Close(l)
+
+	traceA := "0af7651916cd43dd8448eb211c80319c"
+	traceB := "4bf92f3577b34da6a3ce929d0e0e4736"
+	for _, traceHex := range []string{traceA, traceB, traceA, traceB} {
+		l.ErrorWithCtx(spanContext(t, traceHex), "db down", String("dep", "mysql"))
+	}
+	l.ErrorWithCtx(spanContext(t, traceB), "db down", String("dep", "redis"))
+	if err := Sync(l); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := decodeLines(t, buf.Bytes())
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if lines[0]["trace_id"] != traceA || lines[0]["repeated"] != nil {
+		t.Errorf("first line = %v, want trace %s without repeated", lines[0], traceA)
+	}
+	if lines[1]["dep"] != "redis" {
+		t.Errorf("second line = %v, want dep=redis written through", lines[1])
+	}
+	summary := lines[2]
+	if summary["dep"] != "mysql" || summary["repeated"] != float64(3) {
+		t.Errorf("summary = %v, want dep=mysql repeated=3", summary)
+	}
+	if summary["trace_id"] != traceB {
+		t.Errorf("summary trace_id = %v, want last entry's %s", summary["trace_id"], traceB)
+	}
+}
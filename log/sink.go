@@ -0,0 +1,112 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	SinkStdout  = "stdout"
+	SinkStderr  = "stderr"
+	SinkFile    = "file"
+	SinkNetwork = "network"
+	SinkCore    = "core"
+)
+
+// SinkConfig 单个日志输出,Level/Format/字段名/文件与轮转配置沿用 Config
+//
+//	log.NewLogger(&log.Config{Sinks: []log.SinkConfig{
+//		{Type: log.SinkStdout, Config: log.Config{Level: "debug"}},
+//		{Type: log.SinkFile, Config: log.Config{Level: "info", Format: "json", FileName: "log/app.log"}},
+//		{Type: log.SinkFile, Config: log.Config{Level: "error", FileName: "log/error.log"}},
+//		{Type: log.SinkNetwork, Address: "127.0.0.1:5170", Config: log.Config{Format: "json"}},
+//		{Type: log.SinkCore, Core: otlpLogger.Core()},
+//	}}, "multi")
+type SinkConfig struct {
+	Config
+	Type         string        //stdout/stderr/file/network/core
+	Network      string        //network 类型的协议 tcp/udp,默认 tcp
+	Address      string        //network 类型的地址
+	WriteTimeout time.Duration //network 类型的连接与写超时,默认 1s
+	BufferSize   int           //network 类型的待发送队列长度,队列满时丢弃,默认 1024
+	Core         zapcore.Core  //core 类型直接使用的 zap core,如 otlp/logs 导出 OTel 日志的 core
+}
+
+// newSinkCore sink 的级别为硬过滤,未设置时输出全部级别,file 与 network 类型同时返回需要关闭的输出
+func newSinkCore(s SinkConfig) (zapcore.Core, Level, io.Closer, error) {
+	lvl := DebugLevel
+	if s.Level != "" {
+		var err error
+		if lvl, err = ParseLevel(s.Level); err != nil {
+			return nil, lvl, nil, fmt.Errorf("sink %s: %w", s.Type, err)
+		}
+	}
+	var (
+		w      io.Writer
+		closer io.Closer
+	)
+	switch s.Type {
+	case SinkCore:
+		if s.Core == nil {
+			return nil, lvl, nil, errors.New("sink core: nil core")
+		}
+		if s.Level == "" {
+			return s.Core, zapcore.LevelOf(s.Core), nil, nil
+		}
+		core, err := zapcore.NewIncreaseLevelCore(s.Core, lvl)
+		return core, lvl, nil, err
+	case SinkStdout:
+		w = os.Stdout
+	case SinkStderr:
+		w = os.Stderr
+	case SinkFile:
+		conf := s.Config
+		w = initWriter(&conf)
+		closer, _ = w.(io.Closer)
+	case SinkNetwork:
+		if s.Address == "" {
+			return nil, lvl, nil, errors.New("sink network: empty address")
+		}
+		nw := newNetworkWriter(s.Network, s.Address, s.WriteTimeout, s.BufferSize)
+		w, closer = nw, nw
+	default:
+		return nil, lvl, nil, fmt.Errorf("unknown sink type %q", s.Type)
+	}
+	core, closer := newIOCore(&s.Config, zapcore.AddSync(w), lvl, closer)
+	return core, lvl, closer, nil
+}
+
+// newMultiLogger 多个 sink 通过 zapcore.NewTee 组合,Config.Level 为空时取各 sink 的最低级别
+func newMultiLogger(conf *Config) LogCore {
+	if conf == nil {
+		conf = &Config{}
+	}
+	cores := make([]zapcore.Core, 0, len(conf.Sinks))
+	minLevel := FatalLevel
+	var (
+		closers []io.Closer
+		errs    []error
+	)
+	for _, s := range conf.Sinks {
+		core, lvl, closer, err := newSinkCore(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cores = append(cores, core)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		minLevel = min(minLevel, lvl)
+	}
+	level, err := configLevel(conf)
+	if conf.Level == "" {
+		level = minLevel
+	}
+	return newCoreLogger(zapcore.NewTee(cores...), conf, level, closers, errors.Join(append(errs, err)...))
+}
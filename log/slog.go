@@ -0,0 +1,278 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+// callerSkipper 可选接口,适配层调用时跳过自身的调用栈,使 caller 指向业务代码
+type callerSkipper interface {
+	withCallerSkip(skip int) LogCore
+}
+
+func addCallerSkip(l LogCore, skip int) LogCore {
+	if c, ok := l.(callerSkipper); ok && skip > 0 {
+		return c.withCallerSkip(skip)
+	}
+	return l
+}
+
+// pcLogger 可选接口,按调用方给出的 pc 输出 caller,pc 为 0 时不输出 caller
+type pcLogger interface {
+	logPC(ctx context.Context, lvl Level, pc uintptr, msg string, fields []Field)
+}
+
+// sourceField 未实现 pcLogger 的 logger 将 pc 对应的位置作为 source 字段输出
+func sourceField(pc uintptr) Field {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return String(slog.SourceKey, fmt.Sprintf("%s:%d", frame.File, frame.Line))
+}
+
+// slogLevel 转换为 slog 级别,slog 级别间隔为 4,fatal 输出为 ERROR+12
+func slogLevel(lvl Level) slog.Level {
+	return slog.Level(int(lvl) * 4)
+}
+
+func fromSlogLevel(lvl slog.Level) Level {
+	switch {
+	case lvl < slog.LevelInfo:
+		return DebugLevel
+	case lvl < slog.LevelWarn:
+		return InfoLevel
+	case lvl < slog.LevelError:
+		return WarnLevel
+	}
+	return ErrorLevel
+}
+
+// NewSlogHandler 将 LogCore 作为 slog 的输出,context 中的 trace 按 LogCore 的配置输出
+//
+// group 以 "." 拼接在字段名前,ERROR 以上的级别按 error 输出,不会触发 Fatal,
+// caller 取自 slog.Record 的 PC,封装 slog.Logger 的调用方通过 PC 指定位置即可
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(logger)))
+func NewSlogHandler(l LogCore) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+type slogHandler struct {
+	l      LogCore
+	prefix string
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return Enabled(h.l, ctx, fromSlogLevel(lvl))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make([]Field, 0, r.NumAttrs()+1)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	lvl := fromSlogLevel(r.Level)
+	if p, ok := h.l.(pcLogger); ok {
+		p.logPC(ctx, lvl, r.PC, r.Message, fields)
+		return nil
+	}
+	if r.PC != 0 {
+		fields = append(fields, sourceField(r.PC))
+	}
+	switch lvl {
+	case DebugLevel:
+		h.l.DebugWithCtx(ctx, r.Message, fields...)
+	case InfoLevel:
+		h.l.InfoWithCtx(ctx, r.Message, fields...)
+	case WarnLevel:
+		h.l.WarnWithCtx(ctx, r.Message, fields...)
+	default:
+		h.l.ErrorWithCtx(ctx, r.Message, fields...)
+	}
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make([]Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+	return &slogHandler{l: h.l.With(fields...), prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{l: h.l, prefix: h.prefix + name + "."}
+}
+
+// appendAttr 展开 group,空的 attr 与 group 按 slog 的约定忽略
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return append(fields, String(prefix+a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(prefix+a.Key, a.Value.Int64()))
+	case slog.KindBool:
+		return append(fields, Bool(prefix+a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(prefix+a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(prefix+a.Key, a.Value.Time()))
+	case slog.KindGroup:
+	default:
+		return append(fields, Any(prefix+a.Key, a.Value.Any()))
+	}
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		fields = appendAttr(fields, prefix, ga)
+	}
+	return fields
+}
+
+// NewSlogLogger 以任意 slog.Handler 实现 LogCore,Named 的名字作为 logger 字段输出
+//
+// Fatal 输出后退出进程,FatalWithCtx 输出后 panic,与默认实现一致
+func NewSlogLogger(h slog.Handler) LogCore {
+	return &slogLogger{h: h}
+}
+
+type slogLogger struct {
+	h    slog.Handler
+	name string
+}
+
+func (s *slogLogger) Enabled(ctx context.Context, lvl Level) bool {
+	return s.h.Enabled(ctx, slogLevel(lvl))
+}
+
+// log 由各输出方法直接调用,调用栈深度固定
+func (s *slogLogger) log(ctx context.Context, lvl Level, msg string, fields []Field) {
+	if !s.h.Enabled(ctx, slogLevel(lvl)) {
+		return
+	}
+	var pcs [1]uintptr
+	//跳过 runtime.Callers、log 与输出方法
+	runtime.Callers(3, pcs[:])
+	s.logPC(ctx, lvl, pcs[0], msg, fields)
+}
+
+func (s *slogLogger) logPC(ctx context.Context, lvl Level, pc uintptr, msg string, fields []Field) {
+	if !s.h.Enabled(ctx, slogLevel(lvl)) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), slogLevel(lvl), msg, pc)
+	if s.name != "" {
+		r.AddAttrs(slog.String("logger", s.name))
+	}
+	for _, f := range scopedFields(ctx, fields) {
+		r.AddAttrs(f.slogAttr())
+	}
+	for _, f := range fields {
+		r.AddAttrs(f.slogAttr())
+	}
+	_ = s.h.Handle(ctx, r)
+}
+
+// SetPrefix 替换 logger 名
+//
+// Deprecated: 使用 Named
+func (s *slogLogger) SetPrefix(name string) LogCore {
+	return &slogLogger{h: s.h, name: name}
+}
+
+func (s *slogLogger) Named(name string) LogCore {
+	if s.name != "" && name != "" {
+		name = s.name + "." + name
+	} else if name == "" {
+		name = s.name
+	}
+	return &slogLogger{h: s.h, name: name}
+}
+
+func (s *slogLogger) With(fields ...Field) LogCore {
+	if len(fields) == 0 {
+		return s
+	}
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, f.slogAttr())
+	}
+	return &slogLogger{h: s.h.WithAttrs(attrs), name: s.name}
+}
+
+func (s *slogLogger) Debug(args ...interface{}) {
+	s.log(context.Background(), DebugLevel, fmt.Sprint(args...), nil)
+}
+
+func (s *slogLogger) Info(args ...interface{}) {
+	s.log(context.Background(), InfoLevel, fmt.Sprint(args...), nil)
+}
+
+func (s *slogLogger) Warn(args ...interface{}) {
+	s.log(context.Background(), WarnLevel, fmt.Sprint(args...), nil)
+}
+
+func (s *slogLogger) Error(args ...interface{}) {
+	s.log(context.Background(), ErrorLevel, fmt.Sprint(args...), nil)
+}
+
+func (s *slogLogger) Fatal(args ...interface{}) {
+	s.log(context.Background(), FatalLevel, fmt.Sprint(args...), nil)
+	os.Exit(1)
+}
+
+func (s *slogLogger) Debugf(format string, args ...interface{}) {
+	s.log(context.Background(), DebugLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (s *slogLogger) Infof(format string, args ...interface{}) {
+	s.log(context.Background(), InfoLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (s *slogLogger) Warnf(format string, args ...interface{}) {
+	s.log(context.Background(), WarnLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (s *slogLogger) Errorf(format string, args ...interface{}) {
+	s.log(context.Background(), ErrorLevel, fmt.Sprintf(format, args...), nil)
+}
+
+func (s *slogLogger) Fatalf(format string, args ...interface{}) {
+	s.log(context.Background(), FatalLevel, fmt.Sprintf(format, args...), nil)
+	os.Exit(1)
+}
+
+func (s *slogLogger) DebugWithCtx(ctx context.Context, msg string, fields ...Field) {
+	s.log(ctx, DebugLevel, msg, fields)
+}
+
+func (s *slogLogger) InfoWithCtx(ctx context.Context, msg string, fields ...Field) {
+	s.log(ctx, InfoLevel, msg, fields)
+}
+
+func (s *slogLogger) WarnWithCtx(ctx context.Context, msg string, fields ...Field) {
+	s.log(ctx, WarnLevel, msg, fields)
+}
+
+func (s *slogLogger) ErrorWithCtx(ctx context.Context, msg string, fields ...Field) {
+	s.log(ctx, ErrorLevel, msg, fields)
+}
+
+func (s *slogLogger) FatalWithCtx(ctx context.Context, msg string, fields ...Field) {
+	s.log(ctx, FatalLevel, msg, fields)
+	panic(msg)
+}
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// spanEvent 将日志记录为当前 span 的 log 事件,trace 字段由 span 本身携带,不重复记录
+func (l *zapLogger) spanEvent(ctx context.Context, lvl Level, msg string, fields []zapcore.Field) {
+	if !l.spanEvents || lvl < l.spanLevel {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range l.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		if f.Key == l.traceKey || f.Key == l.spanKey || f.Key == l.flagsKey {
+			continue
+		}
+		f.AddTo(enc)
+	}
+	attrs := make([]attribute.KeyValue, 0, len(enc.Fields)+3)
+	attrs = append(attrs,
+		attribute.String("log.severity", lvl.CapitalString()),
+		attribute.String("log.message", msg),
+	)
+	if l.name != "" {
+		attrs = append(attrs, attribute.String("log.logger", l.name))
+	}
+	for k, v := range enc.Fields {
+		attrs = append(attrs, spanAttribute(k, v))
+	}
+	span.AddEvent("log", trace.WithAttributes(attrs...))
+}
+
+func spanAttribute(key string, v interface{}) attribute.KeyValue {
+	switch val := v.(type) {
+	case string:
+		return attribute.String(key, val)
+	case bool:
+		return attribute.Bool(key, val)
+	case int:
+		return attribute.Int(key, val)
+	case int64:
+		return attribute.Int64(key, val)
+	case int32:
+		return attribute.Int64(key, int64(val))
+	case uint32:
+		return attribute.Int64(key, int64(val))
+	case float64:
+		return attribute.Float64(key, val)
+	case float32:
+		return attribute.Float64(key, float64(val))
+	case fmt.Stringer:
+		return attribute.String(key, val.String())
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return attribute.String(key, string(b))
+	}
+	return attribute.String(key, fmt.Sprint(v))
+}
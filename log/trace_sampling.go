@@ -0,0 +1,62 @@
+package log
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	ictx "github.com/guomoumou123/contrib/context"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceSamplingConfig 按 trace 采样日志: 采样的 trace 保留全部日志,未采样的 trace 只保留 MinLevel 及以上,
+// 或按 trace id 确定性保留 Ratio 比例,与 TraceIDRatioBased 算法一致,各服务对同一 trace 的判断相同
+type TraceSamplingConfig struct {
+	MinLevel string  //未采样 trace 保留的最低级别,默认 warn
+	Ratio    float64 //未采样 trace 按 trace id 保留全部日志的比例 0-1
+}
+
+type traceSampler struct {
+	minLevel  Level
+	threshold uint64
+}
+
+func newTraceSampler(conf *TraceSamplingConfig) (*traceSampler, error) {
+	s := &traceSampler{minLevel: WarnLevel}
+	if conf.MinLevel != "" {
+		lvl, err := ParseLevel(conf.MinLevel)
+		if err != nil {
+			return nil, fmt.Errorf("trace sampling level: %w", err)
+		}
+		s.minLevel = lvl
+	}
+	switch {
+	case conf.Ratio >= 1:
+		s.threshold = 1 << 63
+	case conf.Ratio > 0:
+		s.threshold = uint64(conf.Ratio * (1 << 63))
+	}
+	return s, nil
+}
+
+// keep 没有 trace 的日志不参与采样;只有 trace id 没有 flags 时视为未采样
+func (s *traceSampler) keep(ctx context.Context, lvl Level) bool {
+	if lvl >= s.minLevel {
+		return true
+	}
+	var traceId trace.TraceID
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		if sc.IsSampled() {
+			return true
+		}
+		traceId = sc.TraceID()
+	} else if id := ictx.GetTraceId(ctx); id != "" {
+		var err error
+		if traceId, err = trace.TraceIDFromHex(id); err != nil {
+			return true
+		}
+	} else {
+		return true
+	}
+	return binary.BigEndian.Uint64(traceId[8:16])>>1 < s.threshold
+}
@@ -2,11 +2,14 @@ package log
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"sync"
 
 	ictx "github.com/guomoumou123/contrib/context"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gorm.io/gorm/utils"
@@ -15,69 +18,200 @@ import (
 var bufferPool = sync.Pool{New: func() any { return new([]byte) }}
 
 type zapLogger struct {
-	prefix string
-	writer *zap.Logger
+	name       string
+	fields     []zapcore.Field
+	levels     *levelRegistry
+	traceKey   string
+	spanKey    string
+	flagsKey   string
+	spanEvents bool  //日志是否同时记录为 span 事件
+	spanLevel  Level //记录为 span 事件的最低级别
+	sampler    *traceSampler
+	root       *zap.Logger //未设置名字与字段的 logger,子 logger 由此创建
+	writer     *zap.Logger
+	closers    []io.Closer //Close 时依次关闭的输出,子 logger 共享
 }
 
-func newZapLogger(w io.Writer, debug bool) LogCore {
+func newZapLogger(w io.Writer, conf *Config, closer io.Closer) LogCore {
 	ws := zapcore.AddSync(w)
-	//初始化encoder配置
-	encoderCfg := zap.NewDevelopmentEncoderConfig()
-	encoderCfg.EncodeCaller = shortCallerEncoder //日志调用方法
-	encoderCfg.EncodeTime = zapcore.RFC3339TimeEncoder
-	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderCfg), ws, zap.DebugLevel,
-	)
+	//core 放开全部级别,由 zapLogger 按 logger 名与 context 判断
+	core, closer := newIOCore(conf, ws, zap.DebugLevel, closer)
+	level, err := configLevel(conf)
+	var closers []io.Closer
+	if closer != nil {
+		closers = append(closers, closer)
+	}
+	return newCoreLogger(core, conf, level, closers, err)
+}
+
+func newCoreLogger(core zapcore.Core, conf *Config, level Level, closers []io.Closer, confErr error) LogCore {
+	traceKey, spanKey, flagsKey := traceKeys(conf)
+	if conf != nil {
+		var closer io.Closer
+		if core, closer = newSamplingCore(core, conf.Sampling, traceKey, spanKey, flagsKey); closer != nil {
+			//先输出合并的日志再关闭输出
+			closers = append([]io.Closer{closer}, closers...)
+		}
+	}
 	lz := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel), zap.AddCallerSkip(1))
-	return &zapLogger{
-		writer: lz,
+	l := &zapLogger{
+		levels:  newLevelRegistry(level, lz.WithOptions(zap.AddCallerSkip(1))),
+		root:    lz,
+		writer:  lz,
+		closers: closers,
+	}
+	l.traceKey, l.spanKey, l.flagsKey = traceKey, spanKey, flagsKey
+	if conf != nil && conf.SpanEventLevel != "" {
+		lvl, err := ParseLevel(conf.SpanEventLevel)
+		if err != nil {
+			confErr = errors.Join(confErr, fmt.Errorf("span event level: %w", err))
+		} else {
+			l.spanEvents, l.spanLevel = true, lvl
+		}
 	}
+	if conf != nil && conf.TraceSampling != nil {
+		sampler, err := newTraceSampler(conf.TraceSampling)
+		confErr = errors.Join(confErr, err)
+		l.sampler = sampler
+	}
+	if confErr != nil {
+		lz.Warn("invalid log config", zap.Error(confErr), zap.Stringer("level", level))
+	}
+	return l
 }
 
 func shortCallerEncoder(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString("[" + caller.TrimmedPath() + "]")
 }
 
-func (l *zapLogger) InfoWithCtx(ctx context.Context, msg string, fields ...Field) {
-	f := make([]zapcore.Field, 0, len(fields)+1)
+// Enabled 判断日志级别与 trace 采样,调试请求的 context 下开启全部级别
+func (l *zapLogger) Enabled(ctx context.Context, lvl Level) bool {
+	if ictx.IsDebug(ctx) {
+		return true
+	}
+	if lvl < l.levels.level(l.name) {
+		return false
+	}
+	return l.sampler == nil || l.sampler.keep(ctx, lvl)
+}
+
+// Sync 写入缓冲中的日志,异步写入时退出前需要调用
+func (l *zapLogger) Sync() error {
+	return l.writer.Sync()
+}
+
+// Close 写入缓冲中的日志后依次关闭输出
+func (l *zapLogger) Close() error {
+	err := l.writer.Sync()
+	for _, c := range l.closers {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+func (l *zapLogger) Level(name string) Level {
+	return l.levels.level(name)
+}
+
+func (l *zapLogger) SetLevel(name string, lvl Level) {
+	l.levels.set(name, lvl)
+}
+
+func (l *zapLogger) ResetLevel(name string) {
+	l.levels.reset(name)
+}
+
+func (l *zapLogger) Levels() (Level, map[string]Level) {
+	return l.levels.snapshot()
+}
+
+// ctxFields 追加 context 中保存的字段与 trace 关联字段,context 中没有 trace 时不输出
+func (l *zapLogger) ctxFields(ctx context.Context, fields []Field) []zapcore.Field {
+	scoped := scopedFields(ctx, fields)
+	f := make([]zapcore.Field, 0, len(scoped)+len(fields)+3)
+	for _, v := range scoped {
+		f = append(f, v.zapField())
+	}
 	for _, v := range fields {
-		f = append(f, zap.Any(v.key, v.value))
+		f = append(f, v.zapField())
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		if l.traceKey != "" {
+			f = append(f, zap.Stringer(l.traceKey, sc.TraceID()))
+		}
+		if l.spanKey != "" {
+			f = append(f, zap.Stringer(l.spanKey, sc.SpanID()))
+		}
+		if l.flagsKey != "" {
+			f = append(f, zap.Stringer(l.flagsKey, sc.TraceFlags()))
+		}
+	} else if traceId := ictx.GetTraceId(ctx); traceId != "" && l.traceKey != "" {
+		f = append(f, zap.String(l.traceKey, traceId))
 	}
-	traceId := ictx.GetTraceId(ctx)
-	f = append(f, zap.String("trace_id", traceId))
+	return f
+}
+
+func (l *zapLogger) DebugWithCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.Enabled(ctx, zap.DebugLevel) {
+		return
+	}
+	f := l.ctxFields(ctx, fields)
+	l.spanEvent(ctx, zap.DebugLevel, msg, f)
+	l.writer.Debug(msg, f...)
+}
+
+func (l *zapLogger) InfoWithCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.Enabled(ctx, zap.InfoLevel) {
+		return
+	}
+	f := l.ctxFields(ctx, fields)
+	l.spanEvent(ctx, zap.InfoLevel, msg, f)
 	l.writer.Info(msg, f...)
 }
 
 func (l *zapLogger) ErrorWithCtx(ctx context.Context, msg string, fields ...Field) {
-	f := make([]zapcore.Field, 0, len(fields)+1)
-	for _, v := range fields {
-		f = append(f, zap.Any(v.key, v.value))
+	if !l.Enabled(ctx, zap.ErrorLevel) {
+		return
 	}
-	traceId := ictx.GetTraceId(ctx)
-	f = append(f, zap.String("trace_id", traceId))
+	f := l.ctxFields(ctx, fields)
+	l.spanEvent(ctx, zap.ErrorLevel, msg, f)
 	l.writer.Error(msg, f...)
 }
 
 func (l *zapLogger) WarnWithCtx(ctx context.Context, msg string, fields ...Field) {
-	f := make([]zapcore.Field, 0, len(fields)+1)
-	for _, v := range fields {
-		f = append(f, zap.Any(v.key, v.value))
+	if !l.Enabled(ctx, zap.WarnLevel) {
+		return
 	}
-	traceId := ictx.GetTraceId(ctx)
-	f = append(f, zap.String("trace_id", traceId))
+	f := l.ctxFields(ctx, fields)
+	l.spanEvent(ctx, zap.WarnLevel, msg, f)
 	l.writer.Warn(msg, f...)
 }
 
 func (l *zapLogger) FatalWithCtx(ctx context.Context, msg string, fields ...Field) {
-	f := make([]zapcore.Field, 0, len(fields)+1)
-	for _, v := range fields {
-		f = append(f, zap.Any(v.key, v.value))
-	}
-	traceId := ictx.GetTraceId(ctx)
-	f = append(f, zap.String("trace_id", traceId))
+	f := l.ctxFields(ctx, fields)
+	l.spanEvent(ctx, zap.PanicLevel, msg, f)
 	l.writer.Panic(msg, f...)
 }
 
+// logPC caller 取自 pc 而不是调用栈,供 slog 等自带调用位置的适配层使用
+func (l *zapLogger) logPC(ctx context.Context, lvl Level, pc uintptr, msg string, fields []Field) {
+	if !l.Enabled(ctx, lvl) {
+		return
+	}
+	f := l.ctxFields(ctx, fields)
+	l.spanEvent(ctx, lvl, msg, f)
+	ce := l.writer.Check(lvl, msg)
+	if ce == nil {
+		return
+	}
+	ce.Caller = zapcore.EntryCaller{}
+	if pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		ce.Caller = zapcore.EntryCaller{Defined: true, PC: pc, File: frame.File, Line: frame.Line, Function: frame.Function}
+	}
+	ce.Write(f...)
+}
+
 func getBuffer() *[]byte {
 	p := bufferPool.Get().(*[]byte)
 	*p = (*p)[:0]
@@ -91,43 +225,120 @@ func putBuffer(p *[]byte) {
 	bufferPool.Put(p)
 }
 
+// SetPrefix 替换 logger 名
+//
+// Deprecated: 使用 Named
 func (l *zapLogger) SetPrefix(name string) LogCore {
-	newLogger := *l
-	newLogger.prefix = name
-	return &newLogger
+	return l.child(name, l.fields)
+}
+
+// Named 返回子 logger,名字以 "." 追加在当前名字之后,按名字输出并匹配级别设置
+func (l *zapLogger) Named(name string) LogCore {
+	if l.name != "" && name != "" {
+		name = l.name + "." + name
+	} else if name == "" {
+		name = l.name
+	}
+	return l.child(name, l.fields)
+}
+
+// With 返回绑定字段的子 logger,字段输出在每条日志中
+func (l *zapLogger) With(fields ...Field) LogCore {
+	f := make([]zapcore.Field, 0, len(l.fields)+len(fields))
+	f = append(f, l.fields...)
+	for _, v := range fields {
+		f = append(f, v.zapField())
+	}
+	return l.child(l.name, f)
+}
+
+func (l *zapLogger) child(name string, fields []zapcore.Field) *zapLogger {
+	c := *l
+	c.name = name
+	c.fields = fields
+	c.writer = l.root.Named(name).With(fields...)
+	return &c
+}
+
+// withCallerSkip 子 logger 由 root 创建,skip 一并设置在 root 上
+func (l *zapLogger) withCallerSkip(skip int) LogCore {
+	c := *l
+	c.root = l.root.WithOptions(zap.AddCallerSkip(skip))
+	c.writer = l.writer.WithOptions(zap.AddCallerSkip(skip))
+	return &c
 }
 
 func (l *zapLogger) Info(args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.InfoLevel) {
+		return
+	}
 	buf := getBuffer()
 	defer putBuffer(buf)
-	msg := fmt.Append(*buf, args...)
-	l.writer.Info(fmt.Sprintf("%s %v", l.prefix, string(msg)))
+	l.writer.Info(string(fmt.Append(*buf, args...)))
 }
 
 func (l *zapLogger) Error(args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.ErrorLevel) {
+		return
+	}
 	buf := getBuffer()
 	defer putBuffer(buf)
-	msg := fmt.Append(*buf, args...)
-	l.writer.Error(fmt.Sprintf("%s %v", l.prefix, string(msg)))
+	l.writer.Error(string(fmt.Append(*buf, args...)))
 }
 
 func (l *zapLogger) Warn(args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.WarnLevel) {
+		return
+	}
 	buf := getBuffer()
 	defer putBuffer(buf)
 	msg := fmt.Append(*buf, args...)
-	l.writer.Warn(fmt.Sprintf("%s %s %v", utils.FileWithLineNum(), l.prefix, string(msg)))
+	l.writer.Warn(fmt.Sprintf("%s %s", utils.FileWithLineNum(), msg))
 }
 
 func (l *zapLogger) Debug(args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.DebugLevel) {
+		return
+	}
 	buf := getBuffer()
 	defer putBuffer(buf)
-	msg := fmt.Append(*buf, args...)
-	l.writer.Debug(fmt.Sprintf("%s %v", l.prefix, string(msg)))
+	l.writer.Debug(string(fmt.Append(*buf, args...)))
 }
 
 func (l *zapLogger) Fatal(args ...interface{}) {
 	buf := getBuffer()
 	defer putBuffer(buf)
-	msg := fmt.Append(*buf, args...)
-	l.writer.Fatal(fmt.Sprintf("%s %v", l.prefix, string(msg)))
+	l.writer.Fatal(string(fmt.Append(*buf, args...)))
+}
+
+func (l *zapLogger) Debugf(format string, args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.DebugLevel) {
+		return
+	}
+	l.writer.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *zapLogger) Infof(format string, args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.InfoLevel) {
+		return
+	}
+	l.writer.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *zapLogger) Warnf(format string, args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.WarnLevel) {
+		return
+	}
+	l.writer.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *zapLogger) Errorf(format string, args ...interface{}) {
+	if !l.Enabled(context.Background(), zap.ErrorLevel) {
+		return
+	}
+	l.writer.Error(fmt.Sprintf(format, args...))
+}
+
+func (l *zapLogger) Fatalf(format string, args ...interface{}) {
+	l.writer.Fatal(fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+type CaptureMode int
+
+const (
+	CaptureAlways        CaptureMode = iota //始终记录响应 body
+	CaptureOnError                          //状态码为 4xx/5xx 时记录
+	CaptureOnSlow                           //耗时超过 SlowThreshold 时记录
+	CaptureOnErrorOrSlow                    //出错或慢请求时记录
+	CaptureNever                            //不记录,调试请求除外
+)
+
+// BodyCapture 响应 body 捕获策略,响应先缓冲最多 MaxBytes 字节,请求结束后按条件输出到日志与 span
+type BodyCapture struct {
+	Mode          CaptureMode
+	MaxBytes      int //缓冲上限,<=0 时使用 WithBodyLogLimit 的值
+	SlowThreshold time.Duration
+}
+
+func (c BodyCapture) match(status int, latency time.Duration) bool {
+	isError := status >= http.StatusBadRequest
+	isSlow := c.SlowThreshold > 0 && latency >= c.SlowThreshold
+	switch c.Mode {
+	case CaptureAlways:
+		return true
+	case CaptureOnError:
+		return isError
+	case CaptureOnSlow:
+		return isSlow
+	case CaptureOnErrorOrSlow:
+		return isError || isSlow
+	}
+	return false
+}
+
+func appendLimited(dst, b []byte, limit int) []byte {
+	if limit > 0 {
+		room := limit - len(dst)
+		if room <= 0 {
+			return dst
+		}
+		if len(b) > room {
+			b = b[:room]
+		}
+	}
+	return append(dst, b...)
+}
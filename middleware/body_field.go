@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/guomoumou123/contrib/log"
+	"go.uber.org/zap/zapcore"
+)
+
+var defaultBodyFieldDepth = 5
+
+// bodyValue 按 Content-Type 将 body 转换为结构化日志值: JSON 解析为嵌套对象,表单解析为 key/value,
+// 超过 depth 的层级以 JSON 字符串输出,解析失败或 depth<=0 时退化为字符串
+func bodyValue(contentType string, body []byte, depth int) interface{} {
+	if len(body) == 0 || depth <= 0 {
+		return string(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil || dec.More() {
+			return string(body)
+		}
+		switch val := v.(type) {
+		case map[string]interface{}:
+			return jsonObject{m: val, depth: depth}
+		case []interface{}:
+			return jsonArray{a: val, depth: depth}
+		}
+		return string(body)
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		return valuesObject(values)
+	}
+	return string(body)
+}
+
+// valueField 对象按 log.Object 输出,字符串按 log.String 输出,数组按 log.Any 输出
+func valueField(key string, v interface{}) log.Field {
+	switch val := v.(type) {
+	case zapcore.ObjectMarshaler:
+		return log.Object(key, val)
+	case string:
+		return log.String(key, val)
+	}
+	return log.Any(key, v)
+}
+
+// queryValue 查询参数以 key/value 输出,解析失败时保留原始字符串
+func queryValue(rawQuery string, depth int) interface{} {
+	if rawQuery == "" || depth <= 0 {
+		return rawQuery
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	return valuesObject(values)
+}
+
+// 以下类型实现 zap 的对象/数组编码,避免对 map 的反射编码,key 按字典序输出
+
+type valuesObject url.Values
+
+func (v valuesObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range sortedKeys(v) {
+		if values := v[k]; len(values) == 1 {
+			enc.AddString(k, values[0])
+		} else if err := enc.AddArray(k, headerValues(values)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonObject struct {
+	m     map[string]interface{}
+	depth int
+}
+
+func (o jsonObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range sortedKeys(o.m) {
+		if err := addJSONValue(enc, k, o.m[k], o.depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonArray struct {
+	a     []interface{}
+	depth int
+}
+
+func (a jsonArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range a.a {
+		if err := appendJSONValue(enc, v, a.depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addJSONValue(enc zapcore.ObjectEncoder, k string, v interface{}, depth int) error {
+	switch val := v.(type) {
+	case string:
+		enc.AddString(k, val)
+	case bool:
+		enc.AddBool(k, val)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			enc.AddInt64(k, i)
+			return nil
+		}
+		return enc.AddReflected(k, val)
+	case map[string]interface{}:
+		if depth <= 0 {
+			enc.AddString(k, marshalString(val))
+			return nil
+		}
+		return enc.AddObject(k, jsonObject{m: val, depth: depth})
+	case []interface{}:
+		if depth <= 0 {
+			enc.AddString(k, marshalString(val))
+			return nil
+		}
+		return enc.AddArray(k, jsonArray{a: val, depth: depth})
+	default:
+		return enc.AddReflected(k, val)
+	}
+	return nil
+}
+
+func appendJSONValue(enc zapcore.ArrayEncoder, v interface{}, depth int) error {
+	switch val := v.(type) {
+	case string:
+		enc.AppendString(val)
+	case bool:
+		enc.AppendBool(val)
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			enc.AppendInt64(i)
+			return nil
+		}
+		return enc.AppendReflected(val)
+	case map[string]interface{}:
+		if depth <= 0 {
+			enc.AppendString(marshalString(val))
+			return nil
+		}
+		return enc.AppendObject(jsonObject{m: val, depth: depth})
+	case []interface{}:
+		if depth <= 0 {
+			enc.AppendString(marshalString(val))
+			return nil
+		}
+		return enc.AppendArray(jsonArray{a: val, depth: depth})
+	default:
+		return enc.AppendReflected(val)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func marshalString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,306 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	innermetrics "github.com/guomoumou123/contrib/otlp/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Priority 路由优先级,数值越小越重要,过载时优先丢弃低优先级请求
+type Priority int
+
+const (
+	PriorityCritical Priority = iota
+	PriorityHigh
+	PriorityNormal
+	PriorityLow
+	numPriorities
+)
+
+var priorityNames = [numPriorities]string{"critical", "high", "normal", "low"}
+
+func (p Priority) String() string {
+	if p < 0 || p >= numPriorities {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+const (
+	shedReasonQueueFull    = "queue_full"
+	shedReasonQueueTimeout = "queue_timeout"
+	shedReasonCanceled     = "canceled"
+)
+
+const unmatchedRoute = "unmatched"
+
+var (
+	defaultInitialLimit     = 20
+	defaultMinLimit         = 1
+	defaultMaxLimit         = 1000
+	defaultBackoffRatio     = 0.9
+	defaultLatencyThreshold = 5 * time.Second
+	defaultQueueSize        = 100
+	defaultQueueTimeout     = 500 * time.Millisecond
+	defaultRetryAfter       = time.Second
+	defaultPriorityReserve  = 0.1
+)
+
+type ConcurrencyLimitConfig struct {
+	InitialLimit     int                 //初始并发上限
+	MinLimit         int                 //并发上限下界
+	MaxLimit         int                 //并发上限上界
+	BackoffRatio     float64             //过载时上限的乘性衰减系数,每个 LatencyThreshold 周期最多衰减一次
+	LatencyThreshold time.Duration       //请求耗时超过该值视为过载信号
+	QueueSize        int                 //最高优先级的排队长度,低优先级按 PriorityReserve 依次减少
+	PriorityReserve  float64             //每降低一级预留给更高优先级的并发比例,默认 0.1,即 low 在并发达到上限的 70% 时开始排队
+	QueueTimeout     time.Duration       //排队超时时间
+	RetryAfter       time.Duration       //拒绝时返回的 Retry-After
+	RoutePriority    map[string]Priority //路由模板 -> 优先级,未配置的路由为 PriorityNormal
+	PriorityFunc     func(ctx *gin.Context) Priority
+}
+
+func (c *ConcurrencyLimitConfig) withDefaults() {
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = defaultInitialLimit
+	}
+	if c.MinLimit <= 0 {
+		c.MinLimit = defaultMinLimit
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = defaultMaxLimit
+	}
+	if c.MaxLimit < c.MinLimit {
+		c.MaxLimit = c.MinLimit
+	}
+	if c.BackoffRatio <= 0 || c.BackoffRatio >= 1 {
+		c.BackoffRatio = defaultBackoffRatio
+	}
+	if c.LatencyThreshold <= 0 {
+		c.LatencyThreshold = defaultLatencyThreshold
+	}
+	if c.QueueSize < 0 {
+		c.QueueSize = 0
+	} else if c.QueueSize == 0 {
+		c.QueueSize = defaultQueueSize
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = defaultQueueTimeout
+	}
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = defaultRetryAfter
+	}
+	if c.PriorityReserve <= 0 || c.PriorityReserve*float64(numPriorities-1) >= 1 {
+		c.PriorityReserve = defaultPriorityReserve
+	}
+}
+
+type limitWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// aimdLimiter 基于 AIMD 的自适应并发控制: 请求正常完成且并发接近上限时加性增长,
+// 请求耗时超过阈值或服务端出错时乘性衰减
+type aimdLimiter struct {
+	mu       sync.Mutex
+	conf     ConcurrencyLimitConfig
+	limit    float64
+	inflight int
+	queues   [numPriorities]*list.List
+	backoff  time.Time //上次衰减的时间,一个 LatencyThreshold 周期内只衰减一次
+
+	//同步 gauge 在并发数变化时记录,不注册回调,limiter 被回收后不残留
+	inflightGauge metric.Int64Gauge
+	limitGauge    metric.Int64Gauge
+}
+
+func newAIMDLimiter(conf ConcurrencyLimitConfig) *aimdLimiter {
+	l := &aimdLimiter{
+		conf:  conf,
+		limit: math.Min(float64(conf.MaxLimit), math.Max(float64(conf.MinLimit), float64(conf.InitialLimit))),
+	}
+	for i := range l.queues {
+		l.queues[i] = list.New()
+	}
+	return l
+}
+
+// capacity 优先级 p 可占用的并发数,低优先级在更低的并发下开始排队,为高优先级预留容量
+func (l *aimdLimiter) capacity(p Priority) int {
+	n := int(l.limit * (1 - float64(p)*l.conf.PriorityReserve))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (l *aimdLimiter) queueSize(p Priority) int {
+	return int(float64(l.conf.QueueSize) * (1 - float64(p)*l.conf.PriorityReserve))
+}
+
+func (l *aimdLimiter) hasWaiters(upTo Priority) bool {
+	for p := PriorityCritical; p <= upTo; p++ {
+		if l.queues[p].Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *aimdLimiter) acquire(ctx context.Context, p Priority) (bool, string) {
+	l.mu.Lock()
+	if l.inflight < l.capacity(p) && !l.hasWaiters(p) {
+		l.inflight++
+		l.observe()
+		l.mu.Unlock()
+		return true, ""
+	}
+	queue := l.queues[p]
+	if queue.Len() >= l.queueSize(p) {
+		l.mu.Unlock()
+		return false, shedReasonQueueFull
+	}
+	w := &limitWaiter{ready: make(chan struct{})}
+	elem := queue.PushBack(w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.conf.QueueTimeout)
+	defer timer.Stop()
+	reason := shedReasonQueueTimeout
+	select {
+	case <-w.ready:
+		return true, ""
+	case <-timer.C:
+	case <-ctx.Done():
+		reason = shedReasonCanceled
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		//超时与放行同时发生,以放行为准
+		return true, ""
+	}
+	queue.Remove(elem)
+	return false, reason
+}
+
+func (l *aimdLimiter) release(latency time.Duration, overloaded bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if overloaded || latency > l.conf.LatencyThreshold {
+		//同一批慢请求只衰减一次,避免上限被连续乘性衰减到下界
+		if now := time.Now(); now.Sub(l.backoff) >= l.conf.LatencyThreshold {
+			l.limit = math.Max(float64(l.conf.MinLimit), l.limit*l.conf.BackoffRatio)
+			l.backoff = now
+		}
+	} else if float64(l.inflight*2) >= l.limit {
+		l.limit = math.Min(float64(l.conf.MaxLimit), l.limit+1)
+	}
+	for {
+		w := l.dequeue()
+		if w == nil {
+			break
+		}
+		l.inflight++
+		w.granted = true
+		close(w.ready)
+	}
+	l.observe()
+}
+
+func (l *aimdLimiter) observe() {
+	if l.inflightGauge != nil && l.limitGauge != nil {
+		l.inflightGauge.Record(context.Background(), int64(l.inflight))
+		l.limitGauge.Record(context.Background(), int64(l.limit))
+	}
+}
+
+// dequeue 按优先级取出等待中且未超出该优先级容量的请求
+func (l *aimdLimiter) dequeue() *limitWaiter {
+	for p, queue := range l.queues {
+		if l.inflight >= l.capacity(Priority(p)) {
+			break
+		}
+		if front := queue.Front(); front != nil {
+			queue.Remove(front)
+			return front.Value.(*limitWaiter)
+		}
+	}
+	return nil
+}
+
+// ConcurrencyLimit 自适应并发限制与过载保护中间件,需要挂载在 TelemetryTrace 之后才能在 span 上标记被丢弃的请求
+func ConcurrencyLimit(conf ConcurrencyLimitConfig) gin.HandlerFunc {
+	conf.withDefaults()
+	limiter := newAIMDLimiter(conf)
+	retryAfter := strconv.Itoa(int(math.Ceil(conf.RetryAfter.Seconds())))
+
+	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
+	rejected, _ := meter.Int64Counter("web.concurrency.rejected")
+	limiter.inflightGauge, _ = meter.Int64Gauge("web.concurrency.inflight")
+	limiter.limitGauge, _ = meter.Int64Gauge("web.concurrency.limit")
+
+	return func(ctx *gin.Context) {
+		mctx := ctx.Request.Context()
+		priority := routePriority(ctx, &conf)
+		ok, reason := limiter.acquire(mctx, priority)
+		if !ok {
+			attrs := []attribute.KeyValue{
+				attribute.String(ATTR_PRIORITY, priority.String()),
+				attribute.String(ATTR_SHED_REASON, reason),
+			}
+			if span := trace.SpanFromContext(mctx); span.IsRecording() {
+				span.SetAttributes(append(attrs, attribute.Bool(ATTR_SHED, true))...)
+				span.AddEvent("load_shed")
+			}
+			if rejected != nil {
+				rejected.Add(mctx, 1, metric.WithAttributes(append(attrs, attribute.String(ATTR_PATH, metricRoute(ctx)))...))
+			}
+			ctx.Header("Retry-After", retryAfter)
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			status := ctx.Writer.Status()
+			limiter.release(time.Since(start), status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout)
+		}()
+		ctx.Next()
+	}
+}
+
+func routePriority(ctx *gin.Context, conf *ConcurrencyLimitConfig) Priority {
+	if conf.PriorityFunc != nil {
+		return clampPriority(conf.PriorityFunc(ctx))
+	}
+	if p, ok := conf.RoutePriority[ctx.FullPath()]; ok {
+		return clampPriority(p)
+	}
+	return PriorityNormal
+}
+
+func clampPriority(p Priority) Priority {
+	if p < PriorityCritical {
+		return PriorityCritical
+	}
+	if p >= numPriorities {
+		return PriorityLow
+	}
+	return p
+}
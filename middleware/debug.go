@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultDebugHeader = "X-Debug-Trace"
+
+type DebugTraceConfig struct {
+	Header string   //调试请求头,默认 X-Debug-Trace
+	Tokens []string //白名单 token
+	Secret []byte   //签名密钥,签名 token 由 NewDebugToken 生成
+}
+
+// NewDebugToken 生成带过期时间的签名 token,格式为 <过期时间戳>.<hmac-sha256>
+func NewDebugToken(secret []byte, ttl time.Duration) string {
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return expires + "." + hmacHex(secret, expires)
+}
+
+func (c *DebugTraceConfig) match(req *http.Request) bool {
+	if c == nil {
+		return false
+	}
+	token := req.Header.Get(c.Header)
+	if token == "" {
+		return false
+	}
+	for _, t := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	if len(c.Secret) == 0 {
+		return false
+	}
+	expires, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(hmacHex(c.Secret, expires)))
+}
+
+func hmacHex(secret []byte, msg string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ictx "github.com/guomoumou123/contrib/context"
+	innermetrics "github.com/guomoumou123/contrib/otlp/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type FaultKind string
+
+const (
+	FaultLatency  FaultKind = "latency"  //延迟
+	FaultStatus   FaultKind = "status"   //直接返回错误状态码
+	FaultAbort    FaultKind = "abort"    //断开连接
+	FaultTruncate FaultKind = "truncate" //截断响应 body 后断开连接
+)
+
+const (
+	faultSourceHeader = "header"
+	faultSourceRule   = "rule"
+)
+
+var defaultFaultMaxDelay = 10 * time.Second
+
+type Fault struct {
+	Kind     FaultKind
+	Delay    time.Duration //FaultLatency 的延迟
+	Status   int           //FaultStatus 的状态码
+	Truncate int           //FaultTruncate 保留的字节数
+}
+
+type FaultRule struct {
+	Method      string  //为空时匹配全部方法
+	Route       string  //路由模板,为空时匹配全部路由
+	Probability float64 //命中概率 0-1
+	Faults      []Fault
+}
+
+type FaultInjectionConfig struct {
+	Enabled  bool              //默认关闭,生产环境不要开启
+	Header   string            //通过请求头指定故障,如 "latency=200ms,status=503"、"abort"、"truncate=128",为空时不接受请求头
+	Debug    *DebugTraceConfig //请求头故障需携带有效的调试 token,为空时沿用 TelemetryTrace 的 WithDebugTrace 校验结果
+	MaxDelay time.Duration     //延迟上限,请求头指定的延迟超过时按上限处理,默认 10s
+	Rules    []FaultRule
+}
+
+func (c *FaultInjectionConfig) validate() error {
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultFaultMaxDelay
+	}
+	var errs []error
+	for i, rule := range c.Rules {
+		if rule.Probability < 0 || rule.Probability > 1 {
+			errs = append(errs, fmt.Errorf("rule %d: invalid probability %v", i, rule.Probability))
+		}
+		for _, f := range rule.Faults {
+			if err := f.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("rule %d: %w", i, err))
+			} else if f.Delay > c.MaxDelay {
+				errs = append(errs, fmt.Errorf("rule %d: delay %s exceeds max delay %s", i, f.Delay, c.MaxDelay))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f Fault) validate() error {
+	switch f.Kind {
+	case FaultLatency:
+		if f.Delay < 0 {
+			return fmt.Errorf("invalid delay %s", f.Delay)
+		}
+	case FaultStatus:
+		if f.Status < 100 || f.Status > 999 {
+			return fmt.Errorf("invalid status %d", f.Status)
+		}
+	case FaultTruncate:
+		if f.Truncate < 0 {
+			return fmt.Errorf("invalid truncate %d", f.Truncate)
+		}
+	case FaultAbort:
+	default:
+		return fmt.Errorf("unknown fault %q", f.Kind)
+	}
+	return nil
+}
+
+// FaultInjection 故障注入中间件,用于韧性测试,需要挂载在 TelemetryTrace 之后才能在 span 上记录注入的故障
+//
+// 规则无效时 panic;HTTP/2 等无法劫持连接的请求需要用 FaultAbortHandler 包装 engine 才能断开
+func FaultInjection(conf FaultInjectionConfig) gin.HandlerFunc {
+	if !conf.Enabled {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+	if err := conf.validate(); err != nil {
+		panic(fmt.Sprintf("fault injection: %v", err))
+	}
+	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
+	counter, _ := meter.Int64Counter("fault.injected")
+
+	return func(ctx *gin.Context) {
+		faults, source := selectFaults(ctx, &conf)
+		if len(faults) == 0 {
+			ctx.Next()
+			return
+		}
+
+		mctx := ctx.Request.Context()
+		span := trace.SpanFromContext(mctx)
+		var truncate *truncateWriter
+		for _, f := range faults {
+			attrs := []attribute.KeyValue{
+				attribute.String(ATTR_FAULT_KIND, string(f.Kind)),
+				attribute.String(ATTR_FAULT_SOURCE, source),
+			}
+			if counter != nil {
+				counter.Add(mctx, 1, metric.WithAttributes(append(attrs, attribute.String(ATTR_PATH, metricRoute(ctx)))...))
+			}
+			switch f.Kind {
+			case FaultLatency:
+				span.AddEvent("fault.injected", trace.WithAttributes(append(attrs, attribute.Int64("fault.delay_ms", f.Delay.Milliseconds()))...))
+				select {
+				case <-time.After(f.Delay):
+				case <-mctx.Done():
+				}
+			case FaultStatus:
+				span.AddEvent("fault.injected", trace.WithAttributes(append(attrs, attribute.Int("fault.status", f.Status))...))
+				ctx.AbortWithStatus(f.Status)
+				return
+			case FaultAbort:
+				span.AddEvent("fault.injected", trace.WithAttributes(attrs...))
+				ctx.Abort()
+				abortConnection(ctx)
+				return
+			case FaultTruncate:
+				span.AddEvent("fault.injected", trace.WithAttributes(append(attrs, attribute.Int("fault.truncate", f.Truncate))...))
+				truncate = &truncateWriter{ResponseWriter: ctx.Writer, remaining: f.Truncate}
+				ctx.Writer = truncate
+			}
+		}
+
+		ctx.Next()
+		if truncate != nil {
+			ctx.Writer = truncate.ResponseWriter
+			truncate.Flush()
+			abortConnection(ctx)
+		}
+	}
+}
+
+func selectFaults(ctx *gin.Context, conf *FaultInjectionConfig) ([]Fault, string) {
+	if conf.Header != "" {
+		if spec := ctx.GetHeader(conf.Header); spec != "" && headerAllowed(ctx, conf) {
+			if faults, err := ParseFaults(spec); err == nil {
+				for i := range faults {
+					faults[i].Delay = min(faults[i].Delay, conf.MaxDelay)
+				}
+				return faults, faultSourceHeader
+			}
+		}
+	}
+	route := ctx.FullPath()
+	for _, rule := range conf.Rules {
+		if rule.Method != "" && rule.Method != ctx.Request.Method {
+			continue
+		}
+		if rule.Route != "" && rule.Route != route {
+			continue
+		}
+		if rand.Float64() < rule.Probability {
+			return rule.Faults, faultSourceRule
+		}
+	}
+	return nil, ""
+}
+
+// headerAllowed 请求头故障只对携带有效调试 token 的请求生效
+func headerAllowed(ctx *gin.Context, conf *FaultInjectionConfig) bool {
+	if conf.Debug != nil {
+		return conf.Debug.match(ctx.Request)
+	}
+	return ictx.IsDebug(ctx.Request.Context())
+}
+
+// ParseFaults 解析故障描述,多个故障以逗号分隔,如 "latency=200ms,status=503"
+func ParseFaults(spec string) ([]Fault, error) {
+	var faults []Fault
+	for _, item := range strings.Split(spec, ",") {
+		kind, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		f := Fault{Kind: FaultKind(strings.TrimSpace(kind))}
+		value = strings.TrimSpace(value)
+		var err error
+		switch f.Kind {
+		case FaultLatency:
+			f.Delay, err = time.ParseDuration(value)
+		case FaultStatus:
+			f.Status, err = strconv.Atoi(value)
+		case FaultTruncate:
+			f.Truncate, err = strconv.Atoi(value)
+		}
+		if err == nil {
+			err = f.validate()
+		}
+		if err != nil {
+			return nil, err
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+type faultAbortKey struct{}
+
+// FaultAbortHandler 包装 gin engine,不支持劫持连接的请求(如 HTTP/2)在 handler 链结束后通过 http.ErrAbortHandler 断开,
+// 不经过 gin.Recovery
+//
+//	http.ListenAndServe(addr, middleware.FaultAbortHandler(engine))
+func FaultAbortHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		aborted := new(bool)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), faultAbortKey{}, aborted)))
+		if *aborted {
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// abortConnection 劫持并关闭底层连接,不支持劫持时交给 FaultAbortHandler 断开,未包装时只中止 handler 链
+func abortConnection(ctx *gin.Context) {
+	if hijackClose(ctx.Writer) {
+		return
+	}
+	if aborted, ok := ctx.Request.Context().Value(faultAbortKey{}).(*bool); ok {
+		*aborted = true
+	}
+}
+
+func hijackClose(w gin.ResponseWriter) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	conn, _, err := w.Hijack()
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+type truncateWriter struct {
+	gin.ResponseWriter
+	remaining int
+}
+
+func (w *truncateWriter) Write(b []byte) (int, error) {
+	if w.remaining <= 0 {
+		return len(b), nil
+	}
+	n := len(b)
+	if n > w.remaining {
+		b = b[:w.remaining]
+	}
+	written, err := w.ResponseWriter.Write(b)
+	w.remaining -= written
+	if err != nil {
+		return written, err
+	}
+	return n, nil
+}
+
+func (w *truncateWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
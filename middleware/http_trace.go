@@ -8,10 +8,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	ictx "github.com/guomoumou123/contrib/context"
 	"github.com/guomoumou123/contrib/log"
 	innermetrics "github.com/guomoumou123/contrib/otlp/metrics"
 	innertrace "github.com/guomoumou123/contrib/otlp/trace"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/contrib/propagators/b3"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -20,6 +20,7 @@ import (
 	"go.opentelemetry.io/otel/sdk"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
 )
 
 var DefaultTelemetryBucketBoundaries = []float64{
@@ -38,65 +39,172 @@ var noPrintBodyHeader = map[string]string{
 	"application/octet-stream": "application/octet-stream",
 }
 
-type httpResponseWriter struct {
-	gin.ResponseWriter
+// telemetry 中间件构造时确定的状态,所有请求共享
+type telemetry struct {
+	*telemetryConfig
 	serviceName string
 	env         string
-	context     context.Context
 	logger      log.LogCore
+	propagator  propagation.TextMapPropagator
+	counter     metric.Int64Counter
+	histogram   metric.Int64Histogram
+}
+
+type httpResponseWriter struct {
+	gin.ResponseWriter
+	t           *telemetry
+	context     context.Context
 	startTime   time.Time
 	request     *http.Request
 	span        trace.Span
+	debug       bool
+	timing      *serverTiming
+	capture     BodyCapture
+	body        []byte
+	size        int
+	traffic     bool
+	requestBody []byte
+	truncated   bool //采样的请求 body 被截断
+	fullPath    string
+	panicked    bool //handler panic,由外层 Recovery 写 500
 }
 
-func TelemetryTrace(serviceName, env string, logger log.LogCore) gin.HandlerFunc {
+func TelemetryTrace(serviceName, env string, logger log.LogCore, opts ...TelemetryOption) gin.HandlerFunc {
+	t := &telemetry{
+		telemetryConfig: newTelemetryConfig(opts),
+		serviceName:     serviceName,
+		env:             env,
+		logger:          logger,
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+			b3.New(b3.WithInjectEncoding(b3.B3SingleHeader|b3.B3MultipleHeader))),
+	}
+	//全局 MeterProvider 设置前创建的 instrument 会在设置后自动委托
+	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
+	metricName := "web"
+	t.counter, _ = meter.Int64Counter(metricName + ".request")
+	t.histogram, _ = meter.Int64Histogram(metricName+".histogram",
+		metric.WithExplicitBucketBoundaries(DefaultTelemetryBucketBoundaries...))
+
 	return func(ctx *gin.Context) {
 		req := ctx.Request
 		mctx := req.Context()
-		requestBodySize := req.Header.Get("Content-Length")
-		body, err := ctx.GetRawData()
-		if err != nil {
-			logger.ErrorWithCtx(mctx, "[Logger Middleware]", log.Any("错误信息", err.Error()))
-			ctx.Abort()
+		debug := t.debug.match(req)
+		if debug {
+			//调试 token 不进入日志
+			req.Header.Del(t.debug.Header)
+			mctx = ictx.WithDebug(mctx)
 		}
-		req.Body = io.NopCloser(bytes.NewBuffer(body))
-		optionshttp := []otelhttptrace.Option{
-			otelhttptrace.WithPropagators(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
-				b3.New(b3.WithInjectEncoding(b3.B3SingleHeader|b3.B3MultipleHeader)))),
-		}
-		_, _, sctx := otelhttptrace.Extract(mctx, req, optionshttp...)
+		parentCtx := t.propagator.Extract(mctx, propagation.HeaderCarrier(req.Header))
+		sctx := trace.SpanContextFromContext(parentCtx)
 
-		spanCtx, span := otel.GetTracerProvider().Tracer(innertrace.DefaultTracerName).Start(trace.ContextWithRemoteSpanContext(mctx, sctx), requestMethodPath(ctx))
+		var clientIP string
+		var startOpts []trace.SpanStartOption
+		if t.trust != nil {
+			clientIP = t.trust.clientIP(req)
+			if !t.trust.trustContext(req, sctx) {
+				//不可信的上游上下文不参与采样决策,仅作为 link 保留
+				parentCtx = mctx
+				startOpts = append(startOpts, trace.WithNewRoot())
+				if sctx.IsValid() {
+					startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: sctx}))
+				}
+			}
+		} else {
+			clientIP = ctx.ClientIP()
+		}
+		fullPath := ctx.FullPath()
+		spanCtx, span := otel.GetTracerProvider().Tracer(innertrace.DefaultTracerName).Start(parentCtx, requestMethodPath(ctx), startOpts...)
 		defer span.End()
-		span.SetAttributes(
-			semconv.NetProtocolVersion(req.Proto),
-			semconv.HTTPRoute(req.URL.Path),
-			semconv.DeploymentEnvironment(env),
-			semconv.HTTPRequestMethodKey.String(req.Method),
-			semconv.ServiceName(serviceName),
-			attribute.String(ATTR_PARAMS, req.URL.RawQuery),
-			attribute.String(ATTR_REQUEST_BODY, string(body)),
-			attribute.String(ATTR_REQUEST_BODY_SIZE, requestBodySize),
-			attribute.String(ATTR_CLIENT_IP, ctx.ClientIP()),
-			attribute.String("trace_id", span.SpanContext().TraceID().String()),
-		)
+
+		//只在 span 属性、请求日志或流量录制需要时读取 body,且最多读取 bodyLogLimit+1 字节
+		traffic := t.traffic.sampled()
+		infoLog := log.Enabled(logger, spanCtx, log.InfoLevel)
+		var body []byte
+		bodySize := max(int(req.ContentLength), 0)
+		if infoLog || traffic || (span.IsRecording() && t.semconv.legacy()) {
+			var err error
+			if body, err = readBody(req, t.bodyLogLimit); err != nil {
+				logger.ErrorWithCtx(mctx, "[Logger Middleware]", log.String("错误信息", err.Error()))
+				ctx.Abort()
+			}
+			//截断时以 Content-Length 为准,未知时至少为已读取的长度
+			if bodyTruncated(body, t.bodyLogLimit) {
+				bodySize = max(bodySize, len(body))
+			} else {
+				bodySize = len(body)
+			}
+		}
+		//未采样的 span 不构造属性
+		if span.IsRecording() {
+			attrs := append(t.semconv.requestAttributes(req, fullPath, clientIP, bodySize),
+				semconv.DeploymentEnvironment(env),
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.ServiceName(serviceName),
+			)
+			if debug {
+				attrs = append(attrs, attribute.Bool(ATTR_DEBUG, true))
+			}
+			//http.request_body 为自定义属性,只在原有规范下输出
+			if t.semconv.legacy() {
+				attrs = append(attrs, attribute.String(ATTR_REQUEST_BODY, string(truncateBody(body, t.bodyLogLimit))))
+				if bodyTruncated(body, t.bodyLogLimit) {
+					attrs = append(attrs, attribute.Bool(ATTR_REQUEST_BODY_TRUNCATED, true))
+				}
+			}
+			span.SetAttributes(attrs...)
+		}
 
 		now := time.Now()
-		ctx.Request = ctx.Request.Clone(spanCtx)
-		ctx.Writer = httpResponseWriter{
+		var timing *serverTiming
+		if t.timingLimit > 0 {
+			timing = newServerTiming(t.timingLimit, now)
+			spanCtx = context.WithValue(spanCtx, serverTimingKey{}, timing)
+		}
+		ctx.Request = req.WithContext(spanCtx)
+		writer := &httpResponseWriter{
 			ResponseWriter: ctx.Writer,
-			serviceName:    serviceName,
-			env:            env,
+			t:              t,
 			context:        spanCtx,
 			startTime:      now,
-			logger:         logger,
 			span:           span,
 			request:        ctx.Request,
+			debug:          debug,
+			timing:         timing,
+			capture:        t.bodyCapture(fullPath),
+			fullPath:       fullPath,
 		}
-		requestLog(ctx, body, now, logger)
+		if traffic {
+			writer.traffic = true
+			writer.requestBody = truncateBody(body, t.bodyLogLimit)
+			writer.truncated = bodyTruncated(body, t.bodyLogLimit)
+		}
+		ctx.Writer = writer
+		if infoLog {
+			requestLog(ctx, clientIP, body, bodySize, now, t)
+		}
+		//handler panic 时同样记录响应日志、指标与捕获的 body
+		defer func() {
+			if r := recover(); r != nil {
+				writer.panicked = true
+				writer.finish()
+				panic(r)
+			}
+			writer.finish()
+		}()
 		ctx.Next()
+		//handler 未写响应时 gin 会直接写底层 writer,需在此之前设置响应头
+		if !writer.Written() {
+			writer.writeServerTiming()
+		}
+	}
+}
 
+// metricRoute 指标使用的路由模板,未匹配路由的请求统一为 unmatched,避免指标维度无限增长
+func metricRoute(ctx *gin.Context) string {
+	if fullPath := ctx.FullPath(); fullPath != "" {
+		return fullPath
 	}
+	return unmatchedRoute
 }
 
 func requestMethodPath(ctx *gin.Context) string {
@@ -107,70 +215,171 @@ func requestMethodPath(ctx *gin.Context) string {
 	return path
 }
 
-func requestLog(ctx *gin.Context, body []byte, start time.Time, logger log.LogCore) {
+func truncateBody(body []byte, limit int) []byte {
+	if limit > 0 && len(body) > limit {
+		return body[:limit]
+	}
+	return body
+}
+
+func bodyTruncated(body []byte, limit int) bool {
+	return limit > 0 && len(body) > limit
+}
+
+// readBody 最多读取 limit+1 字节用于判断是否截断,已读取的部分与剩余的 body 重新组合供 handler 读取,limit<=0 时读取全部
+func readBody(req *http.Request, limit int) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if limit <= 0 {
+		body, err := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return body, err
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+	return body, err
+}
+
+func requestLog(ctx *gin.Context, clientIP string, body []byte, bodySize int, start time.Time, t *telemetry) {
 	req := ctx.Request
 	mctx := req.Context()
-	for _, v := range noPrintBodyHeader {
-		if ctx.Request.Header.Get("Content-Type") == v {
-			body = nil
-			break
-		}
+	truncated := bodyTruncated(body, t.bodyLogLimit)
+	body = truncateBody(body, t.bodyLogLimit)
+	contentType := req.Header.Get("Content-Type")
+	//调试请求不区分 Content-Type 记录 body
+	if _, ok := noPrintBodyHeader[contentType]; ok && !ictx.IsDebug(mctx) {
+		body = nil
 	}
 
-	logger.InfoWithCtx(
-		mctx,
-		"http.request",
-		log.Any("client_ip", ctx.ClientIP()),
-		log.Any("method", req.Method),
-		log.Any("path", req.URL.Path),
-		log.Any("params", req.URL.RawQuery),
-		log.Any("header", req.Header),
-		log.Any("body", string(body)),
-		log.Any("body_size", len(body)),
-		log.Any("time", start),
-	)
+	fields := []log.Field{
+		log.String("client_ip", clientIP),
+		log.String("method", req.Method),
+		log.String("path", req.URL.Path),
+		valueField("params", queryValue(req.URL.RawQuery, t.fieldDepth)),
+		log.Object("header", headerMarshaler(req.Header)),
+		valueField("body", bodyValue(contentType, body, t.fieldDepth)),
+		log.Int("body_size", bodySize),
+		log.Time("time", start),
+	}
+	if truncated && body != nil {
+		fields = append(fields, log.Bool("body_truncated", true))
+	}
+	t.logger.InfoWithCtx(mctx, "http.request", fields...)
 }
 
-func (w httpResponseWriter) Write(b []byte) (int, error) {
-	resp := w.ResponseWriter
-	n, err := w.ResponseWriter.Write(b)
-	w.logger.InfoWithCtx(
-		w.context,
-		"http.response",
-		log.Any("path", w.request.URL.Path),
-		log.Any("body", string(b)),
-		log.Any("status", resp.Status()),
-		log.Any("response_content_length", len(b)),
-		log.Any("latency", time.Since(w.startTime)),
-	)
-	request := w.request
-	path := request.URL.Path
-	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
-	attrs := []attribute.KeyValue{
-		semconv.ServiceName(w.serviceName),
-		semconv.HTTPRoute(path),
-		semconv.HTTPRequestMethodKey.String(request.Method),
-		semconv.DeploymentEnvironment(w.env),
-		semconv.HTTPResponseStatusCode(w.Status()),
+func (w *httpResponseWriter) writeServerTiming() {
+	if w.timing != nil && !w.Written() {
+		w.Header().Set("Server-Timing", w.timing.header())
 	}
+}
+
+func (w *httpResponseWriter) WriteHeaderNow() {
+	w.writeServerTiming()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *httpResponseWriter) Flush() {
+	w.writeServerTiming()
+	w.ResponseWriter.Flush()
+}
 
-	jagerAttr := append([]attribute.KeyValue{
-		semconv.HTTPResponseBodySize(len(b)),
-	}, attrs...)
-	defer func(startTime time.Time) {
-		metricName := "web"
+func (w *httpResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *httpResponseWriter) Write(b []byte) (int, error) {
+	w.writeServerTiming()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	if w.capture.Mode != CaptureNever || w.traffic || w.debug {
+		w.body = appendLimited(w.body, b[:n], w.capture.MaxBytes)
+	}
+	return n, err
+}
 
-		w.span.SetAttributes(jagerAttr...)
+// finish 请求结束后记录响应日志与指标,按捕获策略决定是否输出缓冲的响应 body
+func (w *httpResponseWriter) finish() {
+	t := w.t
+	latency := time.Since(w.startTime)
+	status := w.Status()
+	if w.panicked && !w.Written() {
+		status = http.StatusInternalServerError
+	}
+	captured := w.capture.match(status, latency) || w.debug
+	if log.Enabled(t.logger, w.context, log.InfoLevel) {
+		fields := []log.Field{
+			log.String("path", w.request.URL.Path),
+			log.Int("status", status),
+			log.Int("response_content_length", w.size),
+			log.Duration("latency", latency),
+		}
+		if captured {
+			fields = append(fields, valueField("body", bodyValue(w.Header().Get("Content-Type"), w.body, t.fieldDepth)))
+		}
+		t.logger.InfoWithCtx(w.context, "http.response", fields...)
+	}
+	//未匹配路由的请求不统计,避免路径无限增长
+	if t.routeStats != nil && w.fullPath != "" {
+		t.routeStats.Record(w.request.Method, w.fullPath, status, latency)
+	}
+	if w.traffic {
+		t.traffic.record(&CapturedExchange{
+			Time:              w.startTime,
+			TraceId:           w.span.SpanContext().TraceID().String(),
+			Method:            w.request.Method,
+			Route:             w.fullPath,
+			Path:              w.request.URL.Path,
+			Query:             w.request.URL.RawQuery,
+			RequestHeader:     w.request.Header,
+			RequestBody:       string(w.requestBody),
+			RequestTruncated:  w.truncated,
+			Status:            status,
+			ResponseHeader:    w.Header(),
+			ResponseBody:      string(w.body),
+			ResponseTruncated: w.size > len(w.body),
+			LatencyMs:         float64(latency) / float64(time.Millisecond),
+		})
+	}
 
-		if counter, err := meter.Int64Counter(metricName + ".request"); err == nil {
-			counter.Add(w.context, 1, metric.WithAttributes(attrs...))
+	attrs := t.semconv.metricAttributes(w.request, w.fullPath, t.serviceName, t.env, status)
+	if w.span.IsRecording() {
+		w.span.SetAttributes(attrs...)
+		w.span.SetAttributes(semconv.HTTPResponseBodySize(w.size))
+		if captured {
+			w.span.SetAttributes(attribute.String(ATTR_RESPONSE_BODY, string(w.body)))
 		}
+	}
+
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	if t.counter != nil {
+		t.counter.Add(w.context, 1, set)
+	}
+	if t.histogram != nil {
+		t.histogram.Record(w.context, latency.Milliseconds(), set)
+	}
+}
+
+type headerMarshaler http.Header
 
-		if histogram, err := meter.Int64Histogram(metricName+".histogram",
-			metric.WithExplicitBucketBoundaries(DefaultTelemetryBucketBoundaries...)); err == nil {
-			histogram.Record(w.context, time.Since(startTime).Milliseconds(),
-				metric.WithAttributes(attrs...))
+// MarshalLogObject 请求头按对象输出,避免 zap 对 map 反射编码
+func (h headerMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range sortedKeys(h) {
+		if err := enc.AddArray(k, headerValues(h[k])); err != nil {
+			return err
 		}
-	}(w.startTime)
-	return n, err
+	}
+	return nil
+}
+
+type headerValues []string
+
+func (v headerValues) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, s := range v {
+		enc.AppendString(s)
+	}
+	return nil
 }
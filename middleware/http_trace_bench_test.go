@@ -0,0 +1,89 @@
+package middleware_test
+
+// 测量 TelemetryTrace 单个请求的开销,覆盖采样/未采样与是否记录 body 的组合
+//
+//	go test ./middleware -run '^$' -bench TelemetryTrace
+//
+// 热路径优化前后(allocs/op,Bare 为不挂载中间件时的 42):
+//
+//	SampledBody     246 -> 192
+//	SampledNoBody   202 -> 157
+//	UnsampledBody   243 -> 185
+//	UnsampledNoBody 199 -> 152
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guomoumou123/contrib/log"
+	"github.com/guomoumou123/contrib/middleware"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	benchRequestBody  = []byte(`{"order_id":"20240101000001","user_id":10086,"items":[{"sku":"A-1","count":2},{"sku":"B-7","count":1}]}`)
+	benchResponseBody = gin.H{"code": 0, "msg": "ok", "data": gin.H{"order_id": "20240101000001", "status": "paid"}}
+)
+
+// benchEngine 按采样与 body 记录方式创建 engine,bare 时不挂载中间件,作为 gin 与 httptest 自身开销的参照
+func benchEngine(b *testing.B, sampled, capture, bare bool) *gin.Engine {
+	b.Helper()
+	gin.SetMode(gin.ReleaseMode)
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewManualReader())))
+	sampler := sdktrace.NeverSample()
+	if sampled {
+		sampler = sdktrace.AlwaysSample()
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler)))
+
+	engine := gin.New()
+	if !bare {
+		logger := log.NewLogger(&log.Config{FileName: os.DevNull}, "file")
+		bc := middleware.BodyCapture{Mode: middleware.CaptureNever}
+		if capture {
+			bc.Mode = middleware.CaptureAlways
+		}
+		engine.Use(middleware.TelemetryTrace("bench", "test", logger, middleware.WithBodyCapture(bc)))
+	}
+	engine.POST("/orders/:id", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, benchResponseBody)
+	})
+	return engine
+}
+
+func runTelemetryBench(b *testing.B, engine *gin.Engine) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/orders/20240101000001?source=app", bytes.NewReader(benchRequestBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func BenchmarkTelemetryTrace_SampledBody(b *testing.B) {
+	runTelemetryBench(b, benchEngine(b, true, true, false))
+}
+
+func BenchmarkTelemetryTrace_SampledNoBody(b *testing.B) {
+	runTelemetryBench(b, benchEngine(b, true, false, false))
+}
+
+func BenchmarkTelemetryTrace_UnsampledBody(b *testing.B) {
+	runTelemetryBench(b, benchEngine(b, false, true, false))
+}
+
+func BenchmarkTelemetryTrace_UnsampledNoBody(b *testing.B) {
+	runTelemetryBench(b, benchEngine(b, false, false, false))
+}
+
+func BenchmarkTelemetryTrace_Bare(b *testing.B) {
+	runTelemetryBench(b, benchEngine(b, false, false, true))
+}
@@ -11,4 +11,133 @@ var (
 	ATTR_REQUEST_BODY      = "http.request_body"
 	ATTR_REQUEST_BODY_SIZE = "http.request_body_size"
 	ATTR_CLIENT_IP         = "http.client_ip"
+	ATTR_PRIORITY          = "http.priority"
+	ATTR_SHED              = "http.shed"
+	ATTR_SHED_REASON       = "http.shed_reason"
+	ATTR_RATE_LIMIT_CLASS  = "http.rate_limit_class"
+	ATTR_FAULT_KIND        = "fault.kind"
+	ATTR_FAULT_SOURCE      = "fault.source"
 )
+
+var (
+	ATTR_DEBUG         = "http.debug"
+	ATTR_RESPONSE_BODY = "http.response_body"
+
+	ATTR_REQUEST_BODY_TRUNCATED = "http.request_body_truncated"
+
+	defaultBodyLogLimit = 64 << 10 //日志与 span 中记录的 body 默认最大字节数,通过 WithBodyLogLimit 修改
+)
+
+type TelemetryOption func(*telemetryConfig)
+
+type telemetryConfig struct {
+	debug        *DebugTraceConfig
+	bodyLogLimit int
+	timingLimit  int
+	trust        *TrustPolicy
+	capture      BodyCapture
+	routeCapture map[string]BodyCapture
+	fieldDepth   int
+	traffic      *trafficRecorder
+	routeStats   *RouteStats
+	semconv      SemconvStability
+}
+
+func newTelemetryConfig(opts []TelemetryOption) *telemetryConfig {
+	conf := &telemetryConfig{
+		bodyLogLimit: defaultBodyLogLimit,
+		fieldDepth:   defaultBodyFieldDepth,
+		semconv:      semconvStabilityFromEnv(),
+	}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	return conf
+}
+
+func (c *telemetryConfig) bodyCapture(route string) BodyCapture {
+	capture, ok := c.routeCapture[route]
+	if !ok {
+		capture = c.capture
+	}
+	if capture.MaxBytes <= 0 {
+		capture.MaxBytes = c.bodyLogLimit
+	}
+	return capture
+}
+
+// WithDebugTrace 开启调试请求头,携带合法 token 的请求强制采样并输出 Debug 日志
+func WithDebugTrace(conf DebugTraceConfig) TelemetryOption {
+	return func(c *telemetryConfig) {
+		if conf.Header == "" {
+			conf.Header = defaultDebugHeader
+		}
+		c.debug = &conf
+	}
+}
+
+// WithBodyLogLimit 设置日志与 span 中记录 body 的最大字节数,<=0 表示不限制
+func WithBodyLogLimit(limit int) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.bodyLogLimit = limit
+	}
+}
+
+// WithServerTiming 输出 Server-Timing 响应头,limit 为除 total 外最多记录的阶段数
+func WithServerTiming(limit int) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.timingLimit = limit
+	}
+}
+
+// WithTrustPolicy 只信任来自可信代理或签名有效的入站 trace 上下文,客户端 IP 同样按可信代理解析
+func WithTrustPolicy(policy TrustPolicy) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.trust = &policy
+	}
+}
+
+// WithBodyCapture 设置默认的响应 body 捕获策略,默认始终记录
+func WithBodyCapture(capture BodyCapture) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.capture = capture
+	}
+}
+
+// WithRouteBodyCapture 按路由模板设置响应 body 捕获策略
+func WithRouteBodyCapture(route string, capture BodyCapture) TelemetryOption {
+	return func(c *telemetryConfig) {
+		if c.routeCapture == nil {
+			c.routeCapture = make(map[string]BodyCapture)
+		}
+		c.routeCapture[route] = capture
+	}
+}
+
+// WithBodyFieldDepth 设置 JSON body 结构化输出的最大层级,<=0 时 body 与查询参数按字符串记录
+func WithBodyFieldDepth(depth int) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.fieldDepth = depth
+	}
+}
+
+// WithTrafficCapture 按比例采样记录脱敏后的完整请求/响应,body 受 WithBodyLogLimit 限制
+func WithTrafficCapture(capture TrafficCaptureConfig) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.traffic = newTrafficRecorder(capture)
+	}
+}
+
+// WithRouteStats 采集按路由的实时统计,通过 RouteStatsHandler 查看
+func WithRouteStats(stats *RouteStats) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.routeStats = stats
+	}
+}
+
+// WithSemconvStability 设置 HTTP 属性规范,默认读取 OTEL_SEMCONV_STABILITY_OPT_IN
+func WithSemconvStability(stability SemconvStability) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.semconv = stability
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guomoumou123/contrib/log"
+	innermetrics "github.com/guomoumou123/contrib/otlp/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk"
+)
+
+// RateLimitKey 限流 key 提取方式,Class 用于指标分类,Extract 返回空字符串时不限流
+type RateLimitKey struct {
+	Class   string
+	Extract func(ctx *gin.Context) string
+}
+
+// ClientIPKey 按连接的对端 IP 限流,不读取 X-Forwarded-For 等客户端可伪造的请求头,
+// 部署在代理之后时使用 TrustedClientIPKey
+func ClientIPKey() RateLimitKey {
+	return RateLimitKey{
+		Class: "client_ip",
+		Extract: func(ctx *gin.Context) string {
+			return remoteIP(ctx.Request)
+		},
+	}
+}
+
+// TrustedClientIPKey 对端为 policy 中的可信代理时按 X-Forwarded-For 解析客户端 IP,policy 为空时与 ClientIPKey 相同
+func TrustedClientIPKey(policy *TrustPolicy) RateLimitKey {
+	if policy == nil {
+		return ClientIPKey()
+	}
+	return RateLimitKey{
+		Class: "client_ip",
+		Extract: func(ctx *gin.Context) string {
+			return policy.clientIP(ctx.Request)
+		},
+	}
+}
+
+func HeaderKey(header string) RateLimitKey {
+	return RateLimitKey{
+		Class: "header",
+		Extract: func(ctx *gin.Context) string {
+			return ctx.GetHeader(header)
+		},
+	}
+}
+
+type RateLimitConfig struct {
+	Key         RateLimitKey         //默认按连接的对端 IP,见 ClientIPKey
+	Limit       RateLimit            //默认限额
+	RouteLimits map[string]RateLimit //路由模板 -> 限额,覆盖默认限额
+	Store       RateLimitStore       //默认使用内存分片存储
+	Logger      log.LogCore
+}
+
+// RateLimiter 按 key 限流中间件,返回 RateLimit-Limit/RateLimit-Remaining/RateLimit-Reset 响应头,被限流时返回 429
+func RateLimiter(conf RateLimitConfig) gin.HandlerFunc {
+	if conf.Key.Extract == nil {
+		conf.Key = ClientIPKey()
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryRateLimitStore(0)
+	}
+
+	meter := otel.Meter(innermetrics.DefaultMeterName, metric.WithInstrumentationVersion(sdk.Version()))
+	allowed, _ := meter.Int64Counter("web.ratelimit.allowed")
+	limited, _ := meter.Int64Counter("web.ratelimit.limited")
+
+	return func(ctx *gin.Context) {
+		route := metricRoute(ctx)
+		limit, ok := conf.RouteLimits[ctx.FullPath()]
+		if !ok {
+			limit = conf.Limit
+		}
+		key := conf.Key.Extract(ctx)
+		if !limit.valid() || key == "" {
+			ctx.Next()
+			return
+		}
+
+		mctx := ctx.Request.Context()
+		res, err := conf.Store.Take(mctx, route+"|"+key, limit)
+		if err != nil {
+			//存储不可用时放行
+			if conf.Logger != nil {
+				conf.Logger.ErrorWithCtx(mctx, "[RateLimit Middleware]", log.String("错误信息", err.Error()))
+			}
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		attrs := metric.WithAttributes(
+			attribute.String(ATTR_RATE_LIMIT_CLASS, conf.Key.Class),
+			attribute.String(ATTR_PATH, route),
+		)
+		if res.Allowed {
+			if allowed != nil {
+				allowed.Add(mctx, 1, attrs)
+			}
+			ctx.Next()
+			return
+		}
+
+		if limited != nil {
+			limited.Add(mctx, 1, attrs)
+		}
+		if conf.Logger != nil {
+			conf.Logger.WarnWithCtx(
+				mctx,
+				"http.rate_limited",
+				log.String("key_class", conf.Key.Class),
+				log.String("key_hash", keyDigest(key)),
+				log.String("path", route),
+				log.Int("limit", res.Limit),
+				log.Duration("retry_after", res.RetryAfter),
+			)
+		}
+		ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		ctx.AbortWithStatus(http.StatusTooManyRequests)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// keyDigest key 可能是 API key 等凭证,日志中只输出截断的哈希用于关联
+func keyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+type RateLimit struct {
+	Rate   int           //每个周期允许的请求数
+	Period time.Duration //周期
+	Burst  int           //令牌桶容量,默认等于 Rate
+}
+
+func (r RateLimit) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Rate)
+}
+
+func (r RateLimit) valid() bool {
+	return r.Rate > 0 && r.Period > 0
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration //令牌桶重新填满所需时间
+	RetryAfter time.Duration //被限流时下一个令牌可用的等待时间
+}
+
+// RateLimitStore 限流状态存储,内存实现只在单实例内生效,多实例共享限额时可实现基于 redis 等的存储
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+var (
+	defaultRateLimitShards = 64
+	rateLimitSweepInterval = time.Minute
+)
+
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	perToken time.Duration
+	last     time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+float64(elapsed)/float64(b.perToken))
+		b.last = now
+	}
+}
+
+type rateLimitShard struct {
+	sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type memoryRateLimitStore struct {
+	shards []*rateLimitShard
+}
+
+// NewMemoryRateLimitStore 基于令牌桶的分片内存存储,shards<=0 时使用默认分片数
+func NewMemoryRateLimitStore(shards int) RateLimitStore {
+	if shards <= 0 {
+		shards = defaultRateLimitShards
+	}
+	s := &memoryRateLimitStore{
+		shards: make([]*rateLimitShard, shards),
+	}
+	for i := range s.shards {
+		s.shards[i] = &rateLimitShard{buckets: make(map[string]*tokenBucket)}
+	}
+	return s
+}
+
+func (s *memoryRateLimitStore) shard(key string) *rateLimitShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *memoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+	capacity := limit.capacity()
+	perToken := limit.Period / time.Duration(limit.Rate)
+
+	sh := s.shard(key)
+	sh.Lock()
+	defer sh.Unlock()
+	if now.Sub(sh.lastSweep) > rateLimitSweepInterval {
+		sh.sweep(now)
+	}
+
+	b, ok := sh.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		sh.buckets[key] = b
+	}
+	//限额配置变更后按新配置计算
+	b.capacity, b.perToken = capacity, perToken
+	b.refill(now)
+
+	res := RateLimitResult{Limit: int(capacity)}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	return res, nil
+}
+
+// sweep 清理已经回满的令牌桶,避免 key 无限增长
+func (sh *rateLimitShard) sweep(now time.Time) {
+	sh.lastSweep = now
+	for k, b := range sh.buckets {
+		if b.refill(now); b.tokens >= b.capacity {
+			delete(sh.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	routeStatsBucketWidth = 10 * time.Second
+	routeStatsBuckets     = 90 //按 10s 分桶保留 15 分钟
+	RouteStatsWindows     = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+	//耗时直方图分桶上界(毫秒),用于估算分位数
+	routeLatencyBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 300, 500, 700, 1000, 2000, 5000, 10000, 30000}
+)
+
+type routeBucket struct {
+	epoch   int64
+	count   int64
+	errors  int64
+	latency []int64
+}
+
+type routeWindow struct {
+	mu      sync.Mutex
+	buckets []routeBucket
+}
+
+// RouteStats 进程内按路由统计请求量、错误率与耗时分位数,不依赖指标后端
+type RouteStats struct {
+	mu     sync.RWMutex
+	start  time.Time
+	routes map[string]*routeWindow
+}
+
+func NewRouteStats() *RouteStats {
+	return &RouteStats{
+		start:  time.Now(),
+		routes: make(map[string]*routeWindow),
+	}
+}
+
+func routeStatsKey(method, route string) string {
+	return method + " " + route
+}
+
+func (s *RouteStats) window(key string) *routeWindow {
+	s.mu.RLock()
+	w, ok := s.routes[key]
+	s.mu.RUnlock()
+	if ok {
+		return w
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok = s.routes[key]; !ok {
+		w = &routeWindow{buckets: make([]routeBucket, routeStatsBuckets)}
+		s.routes[key] = w
+	}
+	return w
+}
+
+// Record 记录一次请求,状态码 >=500 计为错误
+func (s *RouteStats) Record(method, route string, status int, latency time.Duration) {
+	epoch := time.Now().UnixNano() / int64(routeStatsBucketWidth)
+	w := s.window(routeStatsKey(method, route))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		*b = routeBucket{epoch: epoch, latency: b.latency}
+		if b.latency == nil {
+			b.latency = make([]int64, len(routeLatencyBounds)+1)
+		} else {
+			clear(b.latency)
+		}
+	}
+	b.count++
+	if status >= http.StatusInternalServerError {
+		b.errors++
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+	b.latency[sort.SearchFloat64s(routeLatencyBounds, ms)]++
+}
+
+type RouteWindowStats struct {
+	Window    string  `json:"window"`
+	Requests  int64   `json:"requests"`
+	Rate      float64 `json:"rate"`       //每秒请求数
+	ErrorRate float64 `json:"error_rate"` //错误请求占比
+	P50       float64 `json:"p50_ms"`
+	P95       float64 `json:"p95_ms"`
+	P99       float64 `json:"p99_ms"`
+}
+
+// Snapshot 返回路由在各个滑动窗口内的统计
+func (s *RouteStats) Snapshot(method, route string) []RouteWindowStats {
+	s.mu.RLock()
+	w, ok := s.routes[routeStatsKey(method, route)]
+	s.mu.RUnlock()
+	now := time.Now()
+	epoch := now.UnixNano() / int64(routeStatsBucketWidth)
+	uptime := now.Sub(s.start)
+	//当前分桶只覆盖到 now
+	partial := time.Duration(now.UnixNano() - epoch*int64(routeStatsBucketWidth))
+
+	stats := make([]RouteWindowStats, 0, len(RouteStatsWindows))
+	for _, window := range RouteStatsWindows {
+		ws := RouteWindowStats{Window: window.String()}
+		if ok {
+			n := int64(window / routeStatsBucketWidth)
+			latency := make([]int64, len(routeLatencyBounds)+1)
+			var errors int64
+			w.mu.Lock()
+			for _, b := range w.buckets {
+				if b.epoch <= epoch-n || b.epoch > epoch {
+					continue
+				}
+				ws.Requests += b.count
+				errors += b.errors
+				for i, c := range b.latency {
+					latency[i] += c
+				}
+			}
+			w.mu.Unlock()
+			if ws.Requests > 0 {
+				//窗口实际覆盖 n-1 个完整分桶与当前分桶已经过的时间
+				elapsed := min(time.Duration(n-1)*routeStatsBucketWidth+partial, uptime)
+				ws.Rate = float64(ws.Requests) / elapsed.Seconds()
+				ws.ErrorRate = float64(errors) / float64(ws.Requests)
+				ws.P50 = latencyQuantile(latency, ws.Requests, 0.5)
+				ws.P95 = latencyQuantile(latency, ws.Requests, 0.95)
+				ws.P99 = latencyQuantile(latency, ws.Requests, 0.99)
+			}
+		}
+		stats = append(stats, ws)
+	}
+	return stats
+}
+
+// latencyQuantile 在命中的直方图分桶内线性插值估算分位数
+func latencyQuantile(counts []int64, total int64, q float64) float64 {
+	rank := q * float64(total)
+	var seen int64
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if float64(seen+c) >= rank {
+			if i == len(routeLatencyBounds) {
+				return routeLatencyBounds[i-1]
+			}
+			lower := 0.0
+			if i > 0 {
+				lower = routeLatencyBounds[i-1]
+			}
+			return lower + (routeLatencyBounds[i]-lower)*(rank-float64(seen))/float64(c)
+		}
+		seen += c
+	}
+	return routeLatencyBounds[len(routeLatencyBounds)-1]
+}
+
+type RouteInfo struct {
+	Method  string             `json:"method"`
+	Path    string             `json:"path"`
+	Handler string             `json:"handler"`
+	Stats   []RouteWindowStats `json:"stats"`
+}
+
+// RouteStatsHandler 列出 engine 注册的全部路由及实时统计,统计由 TelemetryTrace 的 WithRouteStats 采集
+//
+//	engine.GET("/debug/routes", middleware.RouteStatsHandler(engine, stats))
+func RouteStatsHandler(engine *gin.Engine, stats *RouteStats) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if engine == nil || stats == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		routes := engine.Routes()
+		infos := make([]RouteInfo, 0, len(routes))
+		for _, r := range routes {
+			infos = append(infos, RouteInfo{
+				Method:  r.Method,
+				Path:    r.Path,
+				Handler: r.Handler,
+				Stats:   stats.Snapshot(r.Method, r.Path),
+			})
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			if infos[i].Path != infos[j].Path {
+				return infos[i].Path < infos[j].Path
+			}
+			return infos[i].Method < infos[j].Method
+		})
+		ctx.JSON(http.StatusOK, infos)
+	}
+}
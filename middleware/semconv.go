@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	stablesemconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// SemconvStability 控制 span 与指标使用的 HTTP 属性规范
+type SemconvStability int
+
+const (
+	SemconvLegacy SemconvStability = iota //沿用原有属性: net.protocol.version、原始路径的 http.route 及 http.* 自定义 key
+	SemconvStable                         //稳定版 HTTP 语义约定: url.path、url.query、client.address、network.protocol.version 等
+	SemconvDup                            //同时输出两套属性便于看板迁移,key 冲突时(http.route)保留原有的值,不影响原有看板
+)
+
+var semconvStabilityEnv = "OTEL_SEMCONV_STABILITY_OPT_IN"
+
+// semconvStabilityFromEnv 与 OTel 约定一致: "http" 使用稳定版,"http/dup" 同时输出
+func semconvStabilityFromEnv() SemconvStability {
+	mode := SemconvLegacy
+	for _, v := range strings.Split(os.Getenv(semconvStabilityEnv), ",") {
+		switch strings.TrimSpace(v) {
+		case "http/dup":
+			return SemconvDup
+		case "http":
+			mode = SemconvStable
+		}
+	}
+	return mode
+}
+
+func (s SemconvStability) legacy() bool {
+	return s == SemconvLegacy || s == SemconvDup
+}
+
+func (s SemconvStability) stable() bool {
+	return s == SemconvStable || s == SemconvDup
+}
+
+// requestAttributes 请求开始时写入 span 的属性,route 为路由模板,未匹配路由时为空
+func (s SemconvStability) requestAttributes(req *http.Request, route, clientIP string, bodySize int) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 12)
+	if s.legacy() {
+		attrs = append(attrs,
+			semconv.NetProtocolVersion(req.Proto),
+			attribute.String(ATTR_PARAMS, req.URL.RawQuery),
+			attribute.String(ATTR_REQUEST_BODY_SIZE, req.Header.Get("Content-Length")),
+			attribute.String(ATTR_CLIENT_IP, clientIP),
+		)
+		attrs = append(attrs, semconv.HTTPRoute(req.URL.Path))
+	}
+	if s.stable() {
+		attrs = append(attrs,
+			stablesemconv.NetworkProtocolName("http"),
+			stablesemconv.NetworkProtocolVersion(protocolVersion(req)),
+			stablesemconv.URLPath(req.URL.Path),
+			stablesemconv.URLScheme(scheme(req)),
+			stablesemconv.ClientAddress(clientIP),
+			stablesemconv.HTTPRequestBodySize(bodySize),
+		)
+		if req.URL.RawQuery != "" {
+			attrs = append(attrs, stablesemconv.URLQuery(req.URL.RawQuery))
+		}
+		if route != "" && s == SemconvStable {
+			attrs = append(attrs, stablesemconv.HTTPRoute(route))
+		}
+	}
+	return attrs
+}
+
+// metricAttributes 指标与 span 共用的属性,稳定版只使用路由模板作为 http.route,避免原始路径导致的高基数,
+// 同时输出时与原有规范一致使用原始路径
+func (s SemconvStability) metricAttributes(req *http.Request, route, serviceName, env string, status int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.DeploymentEnvironment(env),
+		semconv.HTTPResponseStatusCode(status),
+	}
+	switch {
+	case s.legacy():
+		attrs = append(attrs, semconv.HTTPRoute(req.URL.Path))
+	case route != "":
+		attrs = append(attrs, stablesemconv.HTTPRoute(route))
+	}
+	return attrs
+}
+
+func protocolVersion(req *http.Request) string {
+	if req.ProtoMinor == 0 && req.ProtoMajor > 1 {
+		return strconv.Itoa(req.ProtoMajor)
+	}
+	return strconv.Itoa(req.ProtoMajor) + "." + strconv.Itoa(req.ProtoMinor)
+}
+
+func scheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	oteltrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type serverTimingKey struct{}
+
+type timingMetric struct {
+	name string
+	dur  time.Duration
+}
+
+// serverTiming 收集一次请求内各阶段耗时,写响应头时输出为 Server-Timing
+type serverTiming struct {
+	mu      sync.Mutex
+	limit   int
+	start   time.Time
+	metrics []timingMetric
+}
+
+func newServerTiming(limit int, start time.Time) *serverTiming {
+	return &serverTiming{
+		limit: limit,
+		start: start,
+	}
+}
+
+// Timing 记录一个阶段的耗时,调用返回的函数结束计时,未开启 Server-Timing 时为空操作
+//
+//	defer middleware.Timing(ctx, "db")()
+func Timing(ctx context.Context, name string) func() {
+	t, ok := ctx.Value(serverTimingKey{}).(*serverTiming)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.add(name, time.Since(start))
+	}
+}
+
+// add 同名阶段的耗时累加,超过 limit 的新阶段被丢弃
+func (t *serverTiming) add(name string, d time.Duration) {
+	name = timingToken(name)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.metrics {
+		if t.metrics[i].name == name {
+			t.metrics[i].dur += d
+			return
+		}
+	}
+	if len(t.metrics) >= t.limit {
+		return
+	}
+	t.metrics = append(t.metrics, timingMetric{name: name, dur: d})
+}
+
+func (t *serverTiming) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var sb strings.Builder
+	for _, m := range t.metrics {
+		writeTimingMetric(&sb, m.name, m.dur)
+		sb.WriteString(", ")
+	}
+	writeTimingMetric(&sb, "total", time.Since(t.start))
+	return sb.String()
+}
+
+func writeTimingMetric(sb *strings.Builder, name string, d time.Duration) {
+	sb.WriteString(name)
+	sb.WriteString(";dur=")
+	sb.WriteString(strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64))
+}
+
+// timingToken 将名称转换为 Server-Timing 允许的 token 字符
+func timingToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// serverTimingProcessor 子 span 开始时从父 context 取得所属请求的 serverTiming,结束时计入
+type serverTimingProcessor struct {
+	spans sync.Map //span id -> *serverTiming
+}
+
+// NewServerTimingProcessor 将请求内结束的子 span(如 gorm 查询、下游 http 调用)计入 Server-Timing,
+// 需要通过 otlp/trace.Config.SpanProcessors 注册,只有被记录的 span 会经过 processor
+func NewServerTimingProcessor() oteltrace.SpanProcessor {
+	return &serverTimingProcessor{}
+}
+
+func (p *serverTimingProcessor) OnStart(parent context.Context, s oteltrace.ReadWriteSpan) {
+	if t, ok := parent.Value(serverTimingKey{}).(*serverTiming); ok {
+		p.spans.Store(s.SpanContext().SpanID(), t)
+	}
+}
+
+func (p *serverTimingProcessor) OnEnd(s oteltrace.ReadOnlySpan) {
+	v, ok := p.spans.LoadAndDelete(s.SpanContext().SpanID())
+	if !ok {
+		return
+	}
+	v.(*serverTiming).add(s.Name(), s.EndTime().Sub(s.StartTime()))
+}
+
+func (p *serverTimingProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *serverTimingProcessor) ForceFlush(context.Context) error { return nil }
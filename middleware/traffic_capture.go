@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guomoumou123/contrib/log"
+)
+
+const RedactedValue = "[REDACTED]"
+
+var (
+	defaultTrafficFile   = "log/traffic.log"
+	defaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key"}
+	defaultRedactFields  = []string{"password", "passwd", "token", "access_token", "refresh_token", "secret"}
+)
+
+type TrafficCaptureConfig struct {
+	SampleRate    float64     //采样比例 0-1
+	Writer        io.Writer   //输出位置,为空时按 File 创建轮转文件
+	File          *log.Config //轮转文件配置,与日志文件使用相同的 lumberjack 配置,文件名默认 log/traffic.log,不要与日志文件相同
+	RedactHeaders []string    //追加脱敏的请求/响应头
+	RedactFields  []string    //追加脱敏的 JSON/表单字段名,不区分大小写
+}
+
+// CapturedExchange 一次采样的请求/响应,按 JSON Lines 写入,供 cmd/replay 回放
+type CapturedExchange struct {
+	Time              time.Time   `json:"time"`
+	TraceId           string      `json:"trace_id"`
+	Method            string      `json:"method"`
+	Route             string      `json:"route"`
+	Path              string      `json:"path"`
+	Query             string      `json:"query"`
+	RequestHeader     http.Header `json:"request_header"`
+	RequestBody       string      `json:"request_body"`
+	RequestTruncated  bool        `json:"request_truncated,omitempty"` //请求 body 超过 WithBodyLogLimit 被截断,回放时跳过
+	Status            int         `json:"status"`
+	ResponseHeader    http.Header `json:"response_header"`
+	ResponseBody      string      `json:"response_body"`
+	ResponseTruncated bool        `json:"response_truncated,omitempty"` //响应 body 被截断,回放时只对比截断前的内容
+	LatencyMs         float64     `json:"latency_ms"`
+}
+
+type trafficRecorder struct {
+	mu            sync.Mutex
+	enc           *json.Encoder
+	sampleRate    float64
+	redactHeaders []string
+	redactFields  map[string]struct{}
+}
+
+func newTrafficRecorder(conf TrafficCaptureConfig) *trafficRecorder {
+	w := conf.Writer
+	if w == nil {
+		//未配置时使用单独的文件,避免与日志文件混在一起并被两个 lumberjack 同时轮转
+		file := log.Config{}
+		if conf.File != nil {
+			file = *conf.File
+		}
+		if file.FileName == "" {
+			file.FileName = defaultTrafficFile
+		}
+		w = log.NewWriter(&file)
+	}
+	r := &trafficRecorder{
+		enc:           json.NewEncoder(w),
+		sampleRate:    conf.SampleRate,
+		redactHeaders: append(append([]string{}, defaultRedactHeaders...), conf.RedactHeaders...),
+		redactFields:  make(map[string]struct{}),
+	}
+	for _, f := range append(append([]string{}, defaultRedactFields...), conf.RedactFields...) {
+		r.redactFields[strings.ToLower(f)] = struct{}{}
+	}
+	return r
+}
+
+func (r *trafficRecorder) sampled() bool {
+	return r != nil && r.sampleRate > 0 && rand.Float64() < r.sampleRate
+}
+
+func (r *trafficRecorder) record(ex *CapturedExchange) {
+	ex.Query = r.redactForm(ex.Query)
+	ex.RequestHeader = r.redactHeader(ex.RequestHeader)
+	ex.ResponseHeader = r.redactHeader(ex.ResponseHeader)
+	ex.RequestBody = r.redactBody(ex.RequestHeader.Get("Content-Type"), ex.RequestBody)
+	ex.ResponseBody = r.redactBody(ex.ResponseHeader.Get("Content-Type"), ex.ResponseBody)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(ex)
+}
+
+func (r *trafficRecorder) redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range r.redactHeaders {
+		if _, ok := h[http.CanonicalHeaderKey(k)]; ok {
+			h.Set(k, RedactedValue)
+		}
+	}
+	return h
+}
+
+// redactBody 对 JSON 与表单 body 按字段名脱敏,JSON 无法解析(如被截断)时整体脱敏
+func (r *trafficRecorder) redactBody(contentType, body string) string {
+	if body == "" {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		dec := json.NewDecoder(strings.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return RedactedValue
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(r.redactValue(v)); err != nil {
+			return RedactedValue
+		}
+		return strings.TrimSuffix(buf.String(), "\n")
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.redactForm(body)
+	}
+	return body
+}
+
+func (r *trafficRecorder) redactForm(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return RedactedValue
+	}
+	for k := range values {
+		if _, ok := r.redactFields[strings.ToLower(k)]; ok {
+			values.Set(k, RedactedValue)
+		}
+	}
+	return values.Encode()
+}
+
+func (r *trafficRecorder) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if _, ok := r.redactFields[strings.ToLower(k)]; ok {
+				val[k] = RedactedValue
+				continue
+			}
+			val[k] = r.redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.redactValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var defaultSignatureHeader = "X-Trace-Signature"
+
+// TrustPolicy 入站 trace 上下文信任策略: 来自可信代理或签名校验通过的请求沿用上游 trace,
+// 否则新建 root span 并以 link 关联上游 trace
+type TrustPolicy struct {
+	TrustedProxies  []netip.Prefix //可信代理网段,同时用于解析客户端 IP
+	SignatureHeader string         //签名请求头,默认 X-Trace-Signature
+	Secret          []byte         //签名密钥,签名由 SignTraceContext 生成
+}
+
+// ParseCIDRs 解析网段列表,单个 IP 视为 /32 或 /128
+func ParseCIDRs(cidrs ...string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// SignTraceContext 生成上游透传 trace 上下文时携带的签名,签名覆盖 trace flags,修改采样标记后签名失效
+func SignTraceContext(secret []byte, sc trace.SpanContext) string {
+	return hmacHex(secret, sc.TraceID().String()+"-"+sc.SpanID().String()+"-"+sc.TraceFlags().String())
+}
+
+func (p *TrustPolicy) trustedAddr(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TrustPolicy) trustContext(req *http.Request, sc trace.SpanContext) bool {
+	if p.trustedAddr(remoteIP(req)) {
+		return true
+	}
+	if len(p.Secret) == 0 || !sc.IsValid() {
+		return false
+	}
+	header := p.SignatureHeader
+	if header == "" {
+		header = defaultSignatureHeader
+	}
+	sig := req.Header.Get(header)
+	return sig != "" && hmac.Equal([]byte(sig), []byte(SignTraceContext(p.Secret, sc)))
+}
+
+// clientIP 仅当对端为可信代理时才解析 X-Forwarded-For,从右往左取第一个不可信地址
+func (p *TrustPolicy) clientIP(req *http.Request) string {
+	ip := remoteIP(req)
+	if !p.trustedAddr(ip) {
+		return ip
+	}
+	//代理可能追加新的请求头行而不是合并到同一行
+	forwarded := strings.Join(req.Header.Values("X-Forwarded-For"), ",")
+	if forwarded == "" {
+		if realIP := strings.TrimSpace(req.Header.Get("X-Real-Ip")); realIP != "" {
+			return realIP
+		}
+		return ip
+	}
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !p.trustedAddr(hop) {
+			return hop
+		}
+		ip = hop
+	}
+	return ip
+}
+
+func remoteIP(req *http.Request) string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(req.RemoteAddr)
+	}
+	return ip
+}
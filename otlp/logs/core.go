@@ -0,0 +1,219 @@
+package logs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// core 将 zap 日志转换为 OTel log record,trace 字段还原为 record 的 trace/span id
+type core struct {
+	zapcore.LevelEnabler
+	logger   otellog.Logger
+	fields   []zapcore.Field
+	traceKey string
+	spanKey  string
+	flagsKey string
+}
+
+func newCore(logger otellog.Logger, config *Config) *core {
+	level := zapcore.InfoLevel
+	if config.Level != "" {
+		if lvl, err := zapcore.ParseLevel(strings.ToLower(config.Level)); err == nil {
+			level = lvl
+		}
+	}
+	return &core{
+		LevelEnabler: level,
+		logger:       logger,
+		traceKey:     keyOr(config.TraceIdKey, "trace_id"),
+		spanKey:      keyOr(config.SpanIdKey, "span_id"),
+		flagsKey:     keyOr(config.FlagsKey, "trace_flags"),
+	}
+}
+
+func keyOr(key, def string) string {
+	if key == "" {
+		return def
+	}
+	return key
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(append(clone.fields, c.fields...), fields...)
+	return &clone
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	var r otellog.Record
+	r.SetTimestamp(ent.Time)
+	r.SetObservedTimestamp(time.Now())
+	r.SetSeverity(severity(ent.Level))
+	r.SetSeverityText(ent.Level.CapitalString())
+	r.SetBody(otellog.StringValue(ent.Message))
+
+	ctx := c.spanContext(enc.Fields)
+	attrs := make([]otellog.KeyValue, 0, len(enc.Fields)+5)
+	if ent.LoggerName != "" {
+		attrs = append(attrs, otellog.String("logger.name", ent.LoggerName))
+	}
+	if ent.Caller.Defined {
+		attrs = append(attrs,
+			otellog.String("code.filepath", ent.Caller.File),
+			otellog.Int("code.lineno", ent.Caller.Line),
+		)
+		if ent.Caller.Function != "" {
+			attrs = append(attrs, otellog.String("code.function", ent.Caller.Function))
+		}
+	}
+	if ent.Stack != "" {
+		attrs = append(attrs, otellog.String("exception.stacktrace", ent.Stack))
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, otellog.KeyValue{Key: k, Value: logValue(enc.Fields[k])})
+	}
+	r.AddAttributes(attrs...)
+	c.logger.Emit(ctx, r)
+	return nil
+}
+
+// spanContext 从日志字段还原 span context 并移除对应字段,字段无效时保留原样
+func (c *core) spanContext(fields map[string]interface{}) context.Context {
+	ctx := context.Background()
+	traceHex, _ := fields[c.traceKey].(string)
+	traceId, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return ctx
+	}
+	cfg := trace.SpanContextConfig{TraceID: traceId}
+	delete(fields, c.traceKey)
+	if spanHex, ok := fields[c.spanKey].(string); ok {
+		if spanId, err := trace.SpanIDFromHex(spanHex); err == nil {
+			cfg.SpanID = spanId
+			delete(fields, c.spanKey)
+		}
+	}
+	if flagsHex, ok := fields[c.flagsKey].(string); ok {
+		if b, err := hex.DecodeString(flagsHex); err == nil && len(b) == 1 {
+			cfg.TraceFlags = trace.TraceFlags(b[0])
+			delete(fields, c.flagsKey)
+		}
+	}
+	return trace.ContextWithSpanContext(ctx, trace.NewSpanContext(cfg))
+}
+
+func (c *core) Sync() error {
+	return nil
+}
+
+func severity(lvl zapcore.Level) otellog.Severity {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return otellog.SeverityDebug
+	case zapcore.InfoLevel:
+		return otellog.SeverityInfo
+	case zapcore.WarnLevel:
+		return otellog.SeverityWarn
+	case zapcore.ErrorLevel:
+		return otellog.SeverityError
+	case zapcore.DPanicLevel:
+		return otellog.SeverityFatal1
+	case zapcore.PanicLevel:
+		return otellog.SeverityFatal2
+	case zapcore.FatalLevel:
+		return otellog.SeverityFatal3
+	}
+	return otellog.SeverityUndefined
+}
+
+// logValue 转换 zapcore.MapObjectEncoder 中的字段值,嵌套对象与数组保留结构
+func logValue(v interface{}) otellog.Value {
+	switch val := v.(type) {
+	case nil:
+		return otellog.Value{}
+	case string:
+		return otellog.StringValue(val)
+	case bool:
+		return otellog.BoolValue(val)
+	case int:
+		return otellog.IntValue(val)
+	case int64:
+		return otellog.Int64Value(val)
+	case int32:
+		return otellog.Int64Value(int64(val))
+	case int16:
+		return otellog.Int64Value(int64(val))
+	case int8:
+		return otellog.Int64Value(int64(val))
+	case uint:
+		return otellog.Int64Value(int64(val))
+	case uint64:
+		return otellog.Int64Value(int64(val))
+	case uint32:
+		return otellog.Int64Value(int64(val))
+	case uint16:
+		return otellog.Int64Value(int64(val))
+	case uint8:
+		return otellog.Int64Value(int64(val))
+	case float64:
+		return otellog.Float64Value(val)
+	case float32:
+		return otellog.Float64Value(float64(val))
+	case []byte:
+		return otellog.BytesValue(val)
+	case time.Time:
+		return otellog.StringValue(val.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otellog.StringValue(val.String())
+	case []interface{}:
+		vs := make([]otellog.Value, 0, len(val))
+		for _, item := range val {
+			vs = append(vs, logValue(item))
+		}
+		return otellog.SliceValue(vs...)
+	case map[string]interface{}:
+		kvs := make([]otellog.KeyValue, 0, len(val))
+		for k, item := range val {
+			kvs = append(kvs, otellog.KeyValue{Key: k, Value: logValue(item)})
+		}
+		return otellog.MapValue(kvs...)
+	case fmt.Stringer:
+		return otellog.StringValue(val.String())
+	case error:
+		return otellog.StringValue(val.Error())
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return otellog.StringValue(string(b))
+	}
+	return otellog.StringValue(fmt.Sprint(v))
+}
@@ -0,0 +1,21 @@
+package logs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func newHttpExporter(ctx context.Context, config *Config) (sdklog.Exporter, error) {
+	exporter, err := otlploghttp.New(
+		ctx,
+		otlploghttp.WithEndpointURL(config.EndpointUrl),
+		otlploghttp.WithCompression(otlploghttp.GzipCompression),
+		otlploghttp.WithInsecure(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return exporter, nil
+}
@@ -0,0 +1,95 @@
+package logs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	innertrace "github.com/guomoumou123/contrib/otlp/trace"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	OTLPLogger interface {
+		//Core 将日志转换为 OTel log record 的 zap core,可作为 log.SinkConfig 的 core 类型输出
+		Core() zapcore.Core
+		Shutdown(ctx context.Context) error
+	}
+
+	closeableLogger struct {
+		core     zapcore.Core
+		provider *sdklog.LoggerProvider
+	}
+)
+
+type Config struct {
+	ServiceName string
+	EndpointUrl string             //OTLP/HTTP logs 地址,如 http://127.0.0.1:4318/v1/logs
+	Level       string             //导出的最低级别,默认 info
+	TraceIdKey  string             //与 log.Config 的 trace 字段名保持一致,默认 trace_id
+	SpanIdKey   string             //默认 span_id
+	FlagsKey    string             //默认 trace_flags
+	Processors  []sdklog.Processor //额外注册的 processor
+}
+
+var DefaultLoggerName = "default"
+
+// FromTraceConfig 沿用 trace 的服务名与地址,地址中的 /v1/traces 替换为 /v1/logs
+func FromTraceConfig(conf *innertrace.Config) *Config {
+	return &Config{
+		ServiceName: conf.ServiceName,
+		EndpointUrl: logsEndpoint(conf.EndpointUrl),
+	}
+}
+
+func logsEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	switch {
+	case strings.HasSuffix(u.Path, "/v1/traces"):
+		u.Path = strings.TrimSuffix(u.Path, "/v1/traces") + "/v1/logs"
+	case u.Path == "" || u.Path == "/":
+		u.Path = "/v1/logs"
+	}
+	return u.String()
+}
+
+func newCloseableLogger(config *Config, exporter sdklog.Exporter) closeableLogger {
+	r, _ := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName)))
+
+	opts := []sdklog.LoggerProviderOption{
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(r),
+	}
+	for _, p := range config.Processors {
+		opts = append(opts, sdklog.WithProcessor(p))
+	}
+	lp := sdklog.NewLoggerProvider(opts...)
+	global.SetLoggerProvider(lp)
+	return closeableLogger{
+		provider: lp,
+		core:     newCore(lp.Logger(DefaultLoggerName), config),
+	}
+}
+
+func (c closeableLogger) Core() zapcore.Core {
+	return c.core
+}
+
+func (c closeableLogger) Shutdown(ctx context.Context) error {
+	return c.provider.Shutdown(ctx)
+}
+
+func NewOTLPLogger(ctx context.Context, config *Config) (OTLPLogger, error) {
+	exporter, err := newHttpExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return newCloseableLogger(config, exporter), nil
+}
@@ -0,0 +1,136 @@
+package logs_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guomoumou123/contrib/log"
+	"github.com/guomoumou123/contrib/otlp/logs"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// newReceiver 模拟 OTLP/HTTP logs 接收端,解码后的请求写入返回的 channel
+func newReceiver(t *testing.T) (*httptest.Server, <-chan *collogspb.ExportLogsServiceRequest) {
+	t.Helper()
+	ch := make(chan *collogspb.ExportLogsServiceRequest, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" {
+			t.Errorf("path = %s, want /v1/logs", r.URL.Path)
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip: %v", err)
+				return
+			}
+			body = gz
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+			return
+		}
+		req := &collogspb.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			t.Errorf("unmarshal: %v", err)
+			return
+		}
+		ch <- req
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		out, _ := proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+		_, _ = w.Write(out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, ch
+}
+
+func attrMap(kvs []*commonpb.KeyValue) map[string]*commonpb.AnyValue {
+	m := make(map[string]*commonpb.AnyValue, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestOTLPLoggerExport(t *testing.T) {
+	srv, ch := newReceiver(t)
+	ctx := context.Background()
+	otlpLogger, err := logs.NewOTLPLogger(ctx, &logs.Config{ServiceName: "order", EndpointUrl: srv.URL + "/v1/logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewLogger(&log.Config{Sinks: []log.SinkConfig{{Type: log.SinkCore, Core: otlpLogger.Core()}}}, "multi")
+
+	traceId, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanId, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	spanCtx := trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	logger.Named("order").WarnWithCtx(spanCtx, "stock low", log.String("sku", "A-1"), log.Int("left", 3))
+	logger.DebugWithCtx(ctx, "filtered by level")
+	if err := otlpLogger.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*logspb.LogRecord
+	var resourceAttrs map[string]*commonpb.AnyValue
+	for len(ch) > 0 {
+		req := <-ch
+		for _, rl := range req.ResourceLogs {
+			resourceAttrs = attrMap(rl.Resource.GetAttributes())
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if got := resourceAttrs["service.name"].GetStringValue(); got != "order" {
+		t.Errorf("service.name = %q, want order", got)
+	}
+
+	rec := records[0]
+	if rec.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_WARN || rec.SeverityText != "WARN" {
+		t.Errorf("severity = %v %q, want WARN", rec.SeverityNumber, rec.SeverityText)
+	}
+	if got := rec.Body.GetStringValue(); got != "stock low" {
+		t.Errorf("body = %q, want stock low", got)
+	}
+	if got := trace.TraceID(rec.TraceId); got != traceId {
+		t.Errorf("trace id = %s, want %s", got, traceId)
+	}
+	if got := trace.SpanID(rec.SpanId); got != spanId {
+		t.Errorf("span id = %s, want %s", got, spanId)
+	}
+	if rec.Flags&uint32(trace.FlagsSampled) == 0 {
+		t.Errorf("flags = %x, want sampled", rec.Flags)
+	}
+
+	attrs := attrMap(rec.Attributes)
+	if got := attrs["sku"].GetStringValue(); got != "A-1" {
+		t.Errorf("sku = %q, want A-1", got)
+	}
+	if got := attrs["left"].GetIntValue(); got != 3 {
+		t.Errorf("left = %d, want 3", got)
+	}
+	if got := attrs["logger.name"].GetStringValue(); got != "order" {
+		t.Errorf("logger.name = %q, want order", got)
+	}
+	for _, key := range []string{"trace_id", "span_id", "trace_flags"} {
+		if _, ok := attrs[key]; ok {
+			t.Errorf("attribute %s should be moved to the record trace context", key)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package trace
+
+import (
+	ictx "github.com/guomoumou123/contrib/context"
+	oteltrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// debugSampler 对标记为调试的请求强制采样,其余请求交给 base 决定
+type debugSampler struct {
+	base oteltrace.Sampler
+}
+
+func newDebugSampler(base oteltrace.Sampler) oteltrace.Sampler {
+	return debugSampler{base: base}
+}
+
+func (s debugSampler) ShouldSample(p oteltrace.SamplingParameters) oteltrace.SamplingResult {
+	if ictx.IsDebug(p.ParentContext) {
+		return oteltrace.SamplingResult{
+			Decision:   oteltrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s debugSampler) Description() string {
+	return "DebugSampler{" + s.base.Description() + "}"
+}
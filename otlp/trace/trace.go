@@ -25,9 +25,10 @@ type (
 )
 
 type Config struct {
-	ServiceName string
-	EndpointUrl string
-	SamplerRate float64 //采样比例值
+	ServiceName    string
+	EndpointUrl    string
+	SamplerRate    float64                   //采样比例值
+	SpanProcessors []oteltrace.SpanProcessor //额外注册的 span processor
 }
 
 var DefaultTracerName = "default"
@@ -35,11 +36,15 @@ var DefaultTracerName = "default"
 func newCloseableTracer(config *Config, exporter oteltrace.SpanExporter) closeableTracer {
 	r, _ := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName)))
 
-	tp := oteltrace.NewTracerProvider(
+	opts := []oteltrace.TracerProviderOption{
 		oteltrace.WithBatcher(exporter),
 		oteltrace.WithResource(r),
-		oteltrace.WithSampler(oteltrace.ParentBased(oteltrace.TraceIDRatioBased(config.SamplerRate))),
-	)
+		oteltrace.WithSampler(newDebugSampler(oteltrace.ParentBased(oteltrace.TraceIDRatioBased(config.SamplerRate)))),
+	}
+	for _, sp := range config.SpanProcessors {
+		opts = append(opts, oteltrace.WithSpanProcessor(sp))
+	}
+	tp := oteltrace.NewTracerProvider(opts...)
 	otel.SetTracerProvider(tp)
 	ct := closeableTracer{
 		provider: tp,